@@ -0,0 +1,161 @@
+package testctx
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultAsyncLogBuffer is the buffer size used by NewAsyncLogger when a
+// non-positive size is given
+const DefaultAsyncLogBuffer = 1024
+
+// AsyncLogger is a Logger that delivers messages to another Logger from a
+// background goroutine, so that a slow sink (an exporter, a file writer)
+// doesn't slow down the test and a panicking sink doesn't kill it.
+//
+// Messages are formatted eagerly and buffered up to a fixed size; when the
+// buffer is full new messages are dropped and counted rather than blocking
+// the caller. Calls to the sink happen one at a time, so the sink does not
+// need to be safe for concurrent use.
+type AsyncLogger struct {
+	sink  Logger
+	queue chan func(Logger)
+	done  chan struct{}
+
+	// mu guards closed against concurrent sends and Close
+	mu     sync.RWMutex
+	closed bool
+
+	dropped atomic.Int64
+	panics  atomic.Int64
+}
+
+var _ Logger = (*AsyncLogger)(nil)
+
+// NewAsyncLogger starts delivering messages to sink in the background,
+// buffering up to bufferSize messages. Close must be called to flush
+// pending messages and stop the background goroutine.
+func NewAsyncLogger(sink Logger, bufferSize int) *AsyncLogger {
+	if bufferSize <= 0 {
+		bufferSize = DefaultAsyncLogBuffer
+	}
+	a := &AsyncLogger{
+		sink:  sink,
+		queue: make(chan func(Logger), bufferSize),
+		done:  make(chan struct{}),
+	}
+	go a.loop()
+	return a
+}
+
+// WithAsyncLogger returns a new wrapper that sends log messages to l through
+// an AsyncLogger. The AsyncLogger is flushed and closed in Cleanup, before
+// the test is marked done; dropped messages and sink panics are reported in
+// the test log.
+func (w *W[T]) WithAsyncLogger(l Logger, bufferSize int) *W[T] {
+	a := NewAsyncLogger(l, bufferSize)
+	w.Cleanup(func() {
+		a.Close()
+		if n := a.Dropped(); n > 0 {
+			w.tb.Logf("testctx: async logger dropped %d messages (buffer full)", n)
+		}
+		if n := a.Panics(); n > 0 {
+			w.tb.Logf("testctx: async logger recovered %d panics from its sink", n)
+		}
+	})
+	return w.WithLogger(a)
+}
+
+// Log queues a Log call to the sink
+func (a *AsyncLogger) Log(args ...any) {
+	msg := sprintln(args...)
+	a.enqueue(func(l Logger) { l.Log(msg) })
+}
+
+// Logf queues a Logf call to the sink
+func (a *AsyncLogger) Logf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	a.enqueue(func(l Logger) { l.Logf("%s", msg) })
+}
+
+// Error queues an Error call to the sink
+func (a *AsyncLogger) Error(args ...any) {
+	msg := sprintln(args...)
+	a.enqueue(func(l Logger) { l.Error(msg) })
+}
+
+// Errorf queues an Errorf call to the sink
+func (a *AsyncLogger) Errorf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	a.enqueue(func(l Logger) { l.Errorf("%s", msg) })
+}
+
+// Flush blocks until every message queued before the call has been
+// delivered to the sink
+func (a *AsyncLogger) Flush() {
+	flushed := make(chan struct{})
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return
+	}
+	// Unlike messages, flush markers are never dropped
+	a.queue <- func(Logger) { close(flushed) }
+	a.mu.RUnlock()
+	<-flushed
+}
+
+// Close stops accepting messages, delivers everything still buffered, and
+// waits for the background goroutine to exit. It is safe to call more than once.
+func (a *AsyncLogger) Close() {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	<-a.done
+}
+
+// Dropped returns the number of messages dropped because the buffer was full
+// or the logger was already closed
+func (a *AsyncLogger) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+// Panics returns the number of panics recovered from the sink
+func (a *AsyncLogger) Panics() int64 {
+	return a.panics.Load()
+}
+
+func (a *AsyncLogger) enqueue(fn func(Logger)) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		a.dropped.Add(1)
+		return
+	}
+	select {
+	case a.queue <- fn:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+func (a *AsyncLogger) loop() {
+	defer close(a.done)
+	for fn := range a.queue {
+		a.deliver(fn)
+	}
+}
+
+// deliver calls fn with the sink, isolating the caller from sink panics
+func (a *AsyncLogger) deliver(fn func(Logger)) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.panics.Add(1)
+		}
+	}()
+	fn(a.sink)
+}
@@ -0,0 +1,85 @@
+package testctx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingLogger blocks every call until unblocked
+type blockingLogger struct {
+	testctx.LogRecorder
+	entered chan struct{}
+	unblock chan struct{}
+}
+
+func (l *blockingLogger) Log(args ...any) {
+	l.entered <- struct{}{}
+	<-l.unblock
+	l.LogRecorder.Log(args...)
+}
+
+// panickingLogger panics on every Error call
+type panickingLogger struct {
+	testctx.LogRecorder
+}
+
+func (l *panickingLogger) Error(args ...any) {
+	panic("boom")
+}
+
+func TestAsyncLoggerFlushesInCleanup(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+
+	tt := testctx.New(t)
+	tt.Run("subtest", func(ctx context.Context, t *testctx.T) {
+		t = t.WithAsyncLogger(rec, 0)
+		for i := range 10 {
+			t.Logf("message %d", i)
+		}
+	})
+
+	// Run returns after cleanup, so every message must have been delivered
+	assert.Len(t, rec.Messages(), 10)
+}
+
+func TestAsyncLoggerDropsWhenFull(t *testing.T) {
+	sink := &blockingLogger{
+		entered: make(chan struct{}, 10),
+		unblock: make(chan struct{}),
+	}
+	a := testctx.NewAsyncLogger(sink, 1)
+
+	// Wait for the first message to reach the sink, so the buffer is empty
+	a.Log("message")
+	<-sink.entered
+
+	start := time.Now()
+	for range 4 {
+		a.Log("message")
+	}
+	assert.Less(t, time.Since(start), time.Second, "logging should not block on a slow sink")
+
+	close(sink.unblock)
+	a.Close()
+
+	// One message is being delivered, one is buffered, the rest are dropped
+	assert.Equal(t, int64(3), a.Dropped())
+	assert.Len(t, sink.Messages(), 2)
+}
+
+func TestAsyncLoggerIsolatesPanics(t *testing.T) {
+	sink := &panickingLogger{}
+	a := testctx.NewAsyncLogger(sink, 0)
+
+	a.Error("first")
+	a.Log("second")
+	a.Flush()
+	a.Close()
+
+	assert.Equal(t, int64(1), a.Panics())
+	assert.Equal(t, []string{"second"}, sink.Messages())
+}
@@ -0,0 +1,24 @@
+package testctx
+
+import "slices"
+
+// Attr is a key/value attribute attached to a test via W.Attr
+type Attr struct {
+	Key   string
+	Value string
+}
+
+// Attrs returns the attributes recorded for the current test via W.Attr, in
+// the order they were set. Middleware can read them once the test finishes,
+// e.g. from a Cleanup func, to forward them elsewhere (such as span attributes).
+func (w *W[T]) Attrs() []Attr {
+	w.state.mu.Lock()
+	defer w.state.mu.Unlock()
+	return slices.Clone(w.state.attrs)
+}
+
+func (w *W[T]) recordAttr(key, value string) {
+	w.state.mu.Lock()
+	defer w.state.mu.Unlock()
+	w.state.attrs = append(w.state.attrs, Attr{Key: key, Value: value})
+}
@@ -0,0 +1,96 @@
+package testctx
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyWaitEvent is the name of the event emitted when a test waited
+// for a slot in a concurrency group; see WithConcurrencyLimit
+const ConcurrencyWaitEvent = "testctx.concurrency_wait"
+
+// WithConcurrencyLimit creates middleware that allows at most n tests in the
+// named group to run at once, across every test using the same group name in
+// the test binary. Tests block until a slot frees up, or fail if their
+// context is done first. A test holds its slot while its body runs, and its
+// subtests run within that slot rather than taking their own; parallel
+// subtests, which run once the body has returned, take their own slots.
+//
+// The limit of a group is fixed by the first test to use it. Tests that had
+// to wait log how long they waited and emit a ConcurrencyWaitEvent with the
+// wait time, so that it shows up in traces.
+//
+// Place WithParallel before it; see WithParallel.
+func WithConcurrencyLimit[T Runner[T]](group string, n int) Middleware[T] {
+	if n < 1 {
+		n = 1
+	}
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			key := concurrencyKey{group}
+			if held, ok := ctx.Value(key).(*concurrencyHold); ok && held.held.Load() {
+				// An enclosing test is holding a slot for us
+				next(ctx, w)
+				return
+			}
+
+			sem := concurrencyGroup(group, n)
+			start := time.Now()
+			select {
+			case sem <- struct{}{}:
+			default:
+				w.Logf("waiting for a slot in concurrency group %q (limit %d)", group, cap(sem))
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					w.Fatalf("gave up waiting for a slot in concurrency group %q after %s: %v",
+						group, time.Since(start).Round(time.Millisecond), context.Cause(ctx))
+				}
+				wait := time.Since(start)
+				w.Logf("acquired a slot in concurrency group %q after %s", group, wait.Round(time.Millisecond))
+				Event(ctx, ConcurrencyWaitEvent,
+					Attr{Key: "group", Value: group},
+					Attr{Key: "limit", Value: strconv.Itoa(cap(sem))},
+					Attr{Key: "wait", Value: wait.String()},
+				)
+			}
+
+			hold := &concurrencyHold{}
+			hold.held.Store(true)
+			defer func() {
+				hold.held.Store(false)
+				<-sem
+			}()
+			next(context.WithValue(ctx, key, hold), w)
+		}
+	}
+}
+
+// concurrencyKey is the context key marking a slot held in a group
+type concurrencyKey struct{ group string }
+
+// concurrencyHold marks a slot held by a test while its body runs
+type concurrencyHold struct {
+	held atomic.Bool
+}
+
+var (
+	concurrencyGroupsMu sync.Mutex
+	concurrencyGroups   = map[string]chan struct{}{}
+)
+
+// concurrencyGroup returns the semaphore for the named group, creating it
+// with n slots if needed
+func concurrencyGroup(group string, n int) chan struct{} {
+	concurrencyGroupsMu.Lock()
+	defer concurrencyGroupsMu.Unlock()
+	sem, ok := concurrencyGroups[group]
+	if !ok {
+		sem = make(chan struct{}, n)
+		concurrencyGroups[group] = sem
+	}
+	return sem
+}
@@ -0,0 +1,95 @@
+package testctx_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	if inSubprocess() {
+		var running, maxRunning atomic.Int32
+		var mu sync.Mutex
+		var waits []string
+
+		ctx := testctx.ContextWithEventHandler(context.Background(), func(_ context.Context, name string, attrs ...testctx.Attr) {
+			if name == testctx.ConcurrencyWaitEvent {
+				mu.Lock()
+				waits = append(waits, attrs[0].Value)
+				mu.Unlock()
+			}
+		})
+		tt := testctx.New(t,
+			testctx.WithParallel(),
+			testctx.WithConcurrencyLimit[*testing.T]("limited", 2),
+		).WithContext(ctx)
+
+		tt.Cleanup(func() {
+			t.Logf("max running: %d", maxRunning.Load())
+			t.Logf("waited in groups: %v", waits)
+		})
+
+		for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+			tt.Run(name, func(ctx context.Context, t *testctx.T) {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(50 * time.Millisecond)
+			})
+		}
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestConcurrencyLimit")
+	assert.True(t, passed)
+	assert.Contains(t, out, "max running: 2")
+	assert.Regexp(t, `waited in groups: \[limited( limited)*\]`, out)
+	assert.Contains(t, out, `waiting for a slot in concurrency group "limited" (limit 2)`)
+	assert.Regexp(t, `acquired a slot in concurrency group "limited" after \d+ms`, out)
+}
+
+func TestConcurrencyLimitNested(t *testing.T) {
+	tt := testctx.New(t, testctx.WithConcurrencyLimit[*testing.T]("nested", 1))
+
+	var ran bool
+	tt.Run("parent", func(ctx context.Context, t *testctx.T) {
+		t.Run("child", func(ctx context.Context, t *testctx.T) {
+			ran = true
+		})
+	})
+	assert.True(t, ran, "subtests should run within the parent's slot")
+}
+
+func TestConcurrencyLimitCanceled(t *testing.T) {
+	if inSubprocess() {
+		tt := testctx.New(t)
+		// The holder pauses inside its body, keeping the group full until
+		// after the waiter's timeout
+		tt.Using(testctx.WithConcurrencyLimit[*testing.T]("canceled", 1)).Run("holder", func(ctx context.Context, t *testctx.T) {
+			t.Unwrap().Parallel()
+			time.Sleep(testctx.ScaleTimeout(300 * time.Millisecond))
+		})
+		tt.Using(
+			testctx.WithParallel(),
+			testctx.WithTimeout[*testing.T](100*time.Millisecond),
+			testctx.WithConcurrencyLimit[*testing.T]("canceled", 1),
+		).Run("waiter", func(ctx context.Context, t *testctx.T) {})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestConcurrencyLimitCanceled")
+	assert.False(t, passed)
+	assert.Contains(t, out, `waiting for a slot in concurrency group "canceled" (limit 1)`)
+	assert.Contains(t, out, `gave up waiting for a slot in concurrency group "canceled"`)
+	assert.Contains(t, out, "context deadline exceeded")
+}
@@ -0,0 +1,189 @@
+package testctx
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DependencyDeclarer can be implemented by containers passed to RunTests to
+// declare that some tests depend on others, e.g. because they use what a
+// previous test built. The returned map is keyed by method name, and lists
+// the methods that must pass before it runs.
+//
+// Tests are run in dependency order, and otherwise in the usual order. A
+// test whose prerequisite fails, is skipped, or is filtered out (e.g. with
+// -run) is skipped with a reason. Dependency cycles and dependencies on
+// unknown methods fail the suite, and the tests involved are skipped.
+//
+// Tests can depend on parallel tests only if they are parallel themselves,
+// since parallel tests don't finish until the rest of the suite has started.
+// Parallel tests wait for their prerequisites while holding one of the
+// -parallel slots, so -parallel must exceed the number of tests that may be
+// waiting at once.
+type DependencyDeclarer interface {
+	Dependencies() map[string][]string
+}
+
+// dependencies tracks the results of the tests in a suite that declares
+// dependencies
+type dependencies[T Runner[T]] struct {
+	// prereqs maps methods to their prerequisites
+	prereqs map[string][]string
+	// order is the order in which to run the methods
+	order []reflect.Method
+	// results maps methods to their results
+	results map[string]*testResult
+}
+
+// testResult is the outcome of a suite method, available once done is
+// closed
+type testResult struct {
+	done chan struct{}
+	once sync.Once
+	// problem describes why dependents must be skipped, if they must
+	problem string
+	// cycle describes the dependency cycle the method is part of, if any
+	cycle string
+	// started is set once the test starts, i.e. if it isn't filtered out
+	started atomic.Bool
+	// running is set while Run is being called for the test
+	running atomic.Bool
+}
+
+func (r *testResult) finish(problem string) {
+	r.once.Do(func() {
+		r.problem = problem
+		close(r.done)
+	})
+}
+
+// newDependencies orders the methods of the container according to the
+// dependencies it declares, reporting problems with them on the suite's
+// test. It returns nil if the container doesn't declare dependencies.
+func newDependencies[T Runner[T]](suite *W[T], container any, methods []reflect.Method) *dependencies[T] {
+	declarer, ok := container.(DependencyDeclarer)
+	if !ok {
+		return nil
+	}
+
+	d := &dependencies[T]{
+		prereqs: declarer.Dependencies(),
+		results: map[string]*testResult{},
+	}
+	for _, method := range methods {
+		d.results[method.Name] = &testResult{done: make(chan struct{})}
+	}
+	for _, method := range methods {
+		for _, prereq := range d.prereqs[method.Name] {
+			if _, ok := d.results[prereq]; !ok {
+				suite.Errorf("%T: %s depends on unknown test %s", container, method.Name, prereq)
+				r := &testResult{done: make(chan struct{})}
+				r.finish("does not exist")
+				d.results[prereq] = r
+			}
+		}
+	}
+
+	// Keep the usual order, except that tests run after their
+	// prerequisites. Cycles are broken by running (and skipping) one of
+	// their tests first.
+	remaining := slices.Clone(methods)
+	isRemaining := func(name string) bool {
+		return slices.ContainsFunc(remaining, func(m reflect.Method) bool { return m.Name == name })
+	}
+	ready := func(m reflect.Method) bool {
+		return !slices.ContainsFunc(d.prereqs[m.Name], isRemaining)
+	}
+	for len(remaining) > 0 {
+		i := slices.IndexFunc(remaining, ready)
+		if i < 0 {
+			for j, m := range remaining {
+				if cycle := d.findCycle(m.Name, isRemaining); cycle != nil {
+					d.results[m.Name].cycle = strings.Join(cycle, " -> ")
+					suite.Errorf("%T: dependency cycle: %s", container, d.results[m.Name].cycle)
+					i = j
+					break
+				}
+			}
+		}
+		d.order = append(d.order, remaining[i])
+		remaining = slices.Delete(remaining, i, i+1)
+	}
+	return d
+}
+
+// findCycle returns a dependency cycle from method back to itself through
+// the remaining methods, or nil if there is none
+func (d *dependencies[T]) findCycle(method string, isRemaining func(string) bool) []string {
+	visited := map[string]bool{}
+	var visit func(path []string) []string
+	visit = func(path []string) []string {
+		for _, prereq := range d.prereqs[path[len(path)-1]] {
+			if prereq == method {
+				return append(slices.Clone(path), method)
+			}
+			if visited[prereq] || !isRemaining(prereq) {
+				continue
+			}
+			visited[prereq] = true
+			if cycle := visit(append(path, prereq)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return visit([]string{method})
+}
+
+// run runs the named suite method as a subtest of w once its prerequisites
+// have passed, skipping it otherwise
+func (d *dependencies[T]) run(w *W[T], name string, fn RunFunc[T]) {
+	r := d.results[name]
+	r.running.Store(true)
+	w.run(name, func(ctx context.Context, t *W[T]) {
+		if r.cycle != "" {
+			t.Skipf("skipped: dependency cycle: %s", r.cycle)
+		}
+		for _, prereq := range d.prereqs[name] {
+			pr := d.results[prereq]
+			select {
+			case <-pr.done:
+			default:
+				if r.running.Load() {
+					// We're blocking the suite, so the prerequisite can't
+					// finish until we do
+					t.Fatalf("%s depends on parallel test %s, so it must be parallel too", name, prereq)
+				}
+				select {
+				case <-pr.done:
+				case <-ctx.Done():
+					t.Fatalf("gave up waiting for prerequisite %s: %v", prereq, context.Cause(ctx))
+				}
+			}
+			if pr.problem != "" {
+				t.Skipf("skipped: prerequisite %s %s", prereq, pr.problem)
+			}
+		}
+		fn(ctx, t)
+	}, func(t *W[T]) {
+		r.started.Store(true)
+		t.Cleanup(func() {
+			switch {
+			case t.Failed():
+				r.finish("failed")
+			case t.Skipped():
+				r.finish("was skipped")
+			default:
+				r.finish("")
+			}
+		})
+	})
+	r.running.Store(false)
+	if !r.started.Load() {
+		r.finish("was filtered out")
+	}
+}
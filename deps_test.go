@@ -0,0 +1,122 @@
+package testctx_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+type pipelineSuite struct {
+	mu  *sync.Mutex
+	ran *[]string
+}
+
+func (s pipelineSuite) Dependencies() map[string][]string {
+	return map[string][]string{
+		"TestDeploy":  {"TestPublish"},
+		"TestPublish": {"TestBuild", "TestLint"},
+	}
+}
+
+func (s pipelineSuite) record(t *testctx.T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.ran = append(*s.ran, t.BaseName())
+}
+
+func (s pipelineSuite) TestDeploy(ctx context.Context, t *testctx.T)  { s.record(t) }
+func (s pipelineSuite) TestPublish(ctx context.Context, t *testctx.T) { s.record(t) }
+func (s pipelineSuite) TestBuild(ctx context.Context, t *testctx.T)   { s.record(t) }
+func (s pipelineSuite) TestLint(ctx context.Context, t *testctx.T)    { s.record(t) }
+
+func TestDependencies(t *testing.T) {
+	var ran []string
+	testctx.New(t).RunTests(pipelineSuite{mu: &sync.Mutex{}, ran: &ran})
+	assert.Equal(t, []string{"TestBuild", "TestLint", "TestPublish", "TestDeploy"}, ran)
+}
+
+func TestDependenciesParallel(t *testing.T) {
+	if inSubprocess() {
+		var ran []string
+		tt := testctx.New(t)
+		tt.Cleanup(func() { t.Logf("ran: %v", ran) })
+		tt.Using(testctx.WithParallel()).RunTests(pipelineSuite{mu: &sync.Mutex{}, ran: &ran})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestDependenciesParallel")
+	assert.True(t, passed)
+	assert.Regexp(t, `ran: \[(TestBuild TestLint|TestLint TestBuild) TestPublish TestDeploy\]`, out)
+}
+
+type brokenPipelineSuite struct{}
+
+func (brokenPipelineSuite) Dependencies() map[string][]string {
+	return map[string][]string{
+		"TestPublish": {"TestBuild"},
+		"TestDeploy":  {"TestPublish"},
+		"TestPing":    {"TestPong"},
+		"TestPong":    {"TestPing"},
+		"TestReport":  {"TestPing"},
+		"TestTypo":    {"TestBiuld"},
+		"TestSerial":  {"TestParallel"},
+	}
+}
+
+func (brokenPipelineSuite) TestBuild(ctx context.Context, t *testctx.T) {
+	t.Error("build failed")
+}
+func (brokenPipelineSuite) TestPublish(ctx context.Context, t *testctx.T) {}
+func (brokenPipelineSuite) TestDeploy(ctx context.Context, t *testctx.T)  {}
+func (brokenPipelineSuite) TestPing(ctx context.Context, t *testctx.T)    {}
+func (brokenPipelineSuite) TestPong(ctx context.Context, t *testctx.T)    {}
+func (brokenPipelineSuite) TestReport(ctx context.Context, t *testctx.T)  {}
+func (brokenPipelineSuite) TestTypo(ctx context.Context, t *testctx.T)    {}
+func (brokenPipelineSuite) TestParallel(ctx context.Context, t *testctx.T) {
+	t.Unwrap().Parallel()
+}
+func (brokenPipelineSuite) TestSerial(ctx context.Context, t *testctx.T) {}
+
+type filteredSuite struct{}
+
+func (filteredSuite) Dependencies() map[string][]string {
+	return map[string][]string{"TestSecond": {"TestFirst"}}
+}
+
+func (filteredSuite) TestFirst(ctx context.Context, t *testctx.T)  {}
+func (filteredSuite) TestSecond(ctx context.Context, t *testctx.T) {}
+
+func TestDependencyFailures(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t).RunTests(brokenPipelineSuite{})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestDependencyFailures")
+	assert.False(t, passed)
+	assert.Contains(t, out, "--- FAIL: TestDependencyFailures/TestBuild")
+	assert.Contains(t, out, "skipped: prerequisite TestBuild failed")
+	assert.Contains(t, out, "skipped: prerequisite TestPublish was skipped")
+	assert.Contains(t, out, "brokenPipelineSuite: dependency cycle: TestPing -> TestPong -> TestPing")
+	assert.Contains(t, out, "skipped: dependency cycle: TestPing -> TestPong -> TestPing")
+	assert.Contains(t, out, "skipped: prerequisite TestPing was skipped")
+	assert.Contains(t, out, "brokenPipelineSuite: TestTypo depends on unknown test TestBiuld")
+	assert.Contains(t, out, "skipped: prerequisite TestBiuld does not exist")
+	assert.Contains(t, out, "TestSerial depends on parallel test TestParallel, so it must be parallel too")
+}
+
+func TestDependencyFiltered(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t).RunTests(filteredSuite{})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestDependencyFiltered/TestSecond")
+	assert.True(t, passed, out)
+	assert.Contains(t, out, "skipped: prerequisite TestFirst was filtered out")
+	assert.Contains(t, out, "--- SKIP: TestDependencyFiltered/TestSecond")
+	assert.NotContains(t, out, "TestDependencyFiltered/TestFirst")
+}
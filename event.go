@@ -0,0 +1,25 @@
+package testctx
+
+import "context"
+
+// EventHandler receives events emitted via Event
+type EventHandler func(ctx context.Context, name string, attrs ...Attr)
+
+// eventHandlerKey is the key used to store the event handler in the context
+type eventHandlerKey struct{}
+
+// ContextWithEventHandler returns a context whose events are sent to h,
+// replacing any handler already set on ctx. Tracing middleware uses this to
+// turn events emitted by other middleware into span events.
+func ContextWithEventHandler(ctx context.Context, h EventHandler) context.Context {
+	return context.WithValue(ctx, eventHandlerKey{}, h)
+}
+
+// Event emits a named event with attributes to the handler set on ctx, if
+// any. Middleware use this to surface noteworthy moments in a test (such as
+// a soft timeout) to observers like tracing.
+func Event(ctx context.Context, name string, attrs ...Attr) {
+	if h, ok := ctx.Value(eventHandlerKey{}).(EventHandler); ok {
+		h(ctx, name, attrs...)
+	}
+}
@@ -0,0 +1,275 @@
+package testctx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockWaitEvent is the name of the event emitted when a test waited for a
+// resource lock; see WithExclusive and WithShared
+const LockWaitEvent = "testctx.lock_wait"
+
+// LockWaitWarning is how long a test waits for a resource lock before it
+// logs which tests are holding it. The message repeats at the same interval
+// until the lock is acquired.
+var LockWaitWarning = 10 * time.Second
+
+// WithExclusive creates middleware that runs the test while holding an
+// exclusive lock on each of the named resources: no other test holding a
+// shared or exclusive lock on any of them runs at the same time. It pairs
+// with WithShared, for tests that can share resources with each other but
+// not with exclusive holders.
+//
+// Locks are acquired in sorted order so that tests locking several
+// resources cannot deadlock each other, and waiting respects the test
+// context. A test holds its locks while its body runs, and its subtests run
+// under them; parallel subtests, which run once the body has returned,
+// acquire their own. Tests that have to wait for more than LockWaitWarning
+// log which tests are holding the locks, and emit a LockWaitEvent with the
+// wait time once they get them.
+//
+// The ordering only covers the keys passed to a single call; when combining
+// several WithExclusive and WithShared middleware, use them in the same
+// order everywhere, after WithParallel (see WithParallel).
+func WithExclusive[T Runner[T]](keys ...string) Middleware[T] {
+	return withResourceLocks[T](true, keys)
+}
+
+// WithShared creates middleware that runs the test while holding a shared
+// lock on each of the named resources: it may run alongside other shared
+// holders, but not alongside exclusive ones. See WithExclusive.
+func WithShared[T Runner[T]](keys ...string) Middleware[T] {
+	return withResourceLocks[T](false, keys)
+}
+
+func withResourceLocks[T Runner[T]](exclusive bool, keys []string) Middleware[T] {
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			held, _ := ctx.Value(resourceLocksKey{}).(*heldLocks)
+			mine := &heldLocks{parent: held, modes: map[string]bool{}}
+			defer mine.releaseAll()
+
+			for _, key := range keys {
+				if excl, ok := held.holding(key); ok {
+					if exclusive && !excl {
+						w.Fatalf("cannot lock %q exclusively while an enclosing test holds a shared lock on it", key)
+					}
+					// An enclosing test is holding the lock for us
+					continue
+				}
+
+				start := time.Now()
+				if err := resourceLocks.acquire(ctx, mine, key, exclusive, w.Name(), w.Logf); err != nil {
+					w.Fatalf("gave up waiting for %s lock on %q after %s: %v",
+						lockMode(exclusive), key, time.Since(start).Round(time.Millisecond), err)
+				}
+				mine.add(key, exclusive)
+			}
+
+			next(context.WithValue(ctx, resourceLocksKey{}, mine), w)
+		}
+	}
+}
+
+func lockMode(exclusive bool) string {
+	if exclusive {
+		return "exclusive"
+	}
+	return "shared"
+}
+
+// resourceLocksKey is the context key for the locks held by enclosing tests
+type resourceLocksKey struct{}
+
+// heldLocks are the resource locks held by a test while its body runs
+type heldLocks struct {
+	parent *heldLocks
+
+	mu       sync.Mutex
+	modes    map[string]bool
+	released bool
+}
+
+// holding reports whether the test or an enclosing test is currently
+// holding a lock on key, and whether it is exclusive
+func (h *heldLocks) holding(key string) (exclusive, ok bool) {
+	for ; h != nil; h = h.parent {
+		h.mu.Lock()
+		exclusive, ok = h.modes[key]
+		ok = ok && !h.released
+		h.mu.Unlock()
+		if ok {
+			return exclusive, true
+		}
+	}
+	return false, false
+}
+
+func (h *heldLocks) add(key string, exclusive bool) {
+	h.mu.Lock()
+	h.modes[key] = exclusive
+	h.mu.Unlock()
+}
+
+func (h *heldLocks) releaseAll() {
+	h.mu.Lock()
+	h.released = true
+	modes := h.modes
+	h.mu.Unlock()
+	for key, exclusive := range modes {
+		resourceLocks.release(key, exclusive, h)
+	}
+}
+
+// resourceLocks holds the state of every resource lock in the test binary
+var resourceLocks = &lockTable{locks: map[string]*resourceLock{}}
+
+type lockTable struct {
+	mu    sync.Mutex
+	locks map[string]*resourceLock
+}
+
+// resourceLock is a reader/writer lock on a named resource that keeps track
+// of its holders
+type resourceLock struct {
+	// exclusive is the name of the exclusive holder, if any
+	exclusive string
+	// shared maps shared holders to their names
+	shared map[*heldLocks]string
+	// exclusiveWaiters is the number of tests waiting for an exclusive
+	// lock; new shared holders wait for them so that they don't starve
+	exclusiveWaiters int
+	// changed is closed and replaced whenever the lock may have become
+	// available
+	changed chan struct{}
+}
+
+func (t *lockTable) get(key string) *resourceLock {
+	l, ok := t.locks[key]
+	if !ok {
+		l = &resourceLock{
+			shared:  map[*heldLocks]string{},
+			changed: make(chan struct{}),
+		}
+		t.locks[key] = l
+	}
+	return l
+}
+
+// acquire blocks until h has acquired the lock on key or ctx is done. name
+// identifies the holder in diagnostics, which are logged via logf.
+func (t *lockTable) acquire(ctx context.Context, h *heldLocks, key string, exclusive bool, name string, logf func(format string, args ...any)) error {
+	start := time.Now()
+	var warn *time.Ticker
+
+	t.mu.Lock()
+	l := t.get(key)
+	for warned := false; ; {
+		var free bool
+		if exclusive {
+			free = l.exclusive == "" && len(l.shared) == 0
+		} else {
+			free = l.exclusive == "" && l.exclusiveWaiters == 0
+		}
+		if free {
+			break
+		}
+		if warned {
+			// Log without holding up every other lock in the table; the lock
+			// may have been released meanwhile, so check again afterwards
+			holders := l.holders()
+			t.mu.Unlock()
+			logf("waiting %s for %s lock on %q, held by: %s",
+				time.Since(start).Round(time.Millisecond), lockMode(exclusive), key, holders)
+			t.mu.Lock()
+			warned = false
+			continue
+		}
+
+		if warn == nil {
+			warn = time.NewTicker(LockWaitWarning)
+			defer warn.Stop()
+			if exclusive {
+				l.exclusiveWaiters++
+				defer func() {
+					t.mu.Lock()
+					l.exclusiveWaiters--
+					// Shared waiters may have been waiting for us
+					l.notify()
+					t.mu.Unlock()
+				}()
+			}
+		}
+		changed := l.changed
+		t.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-warn.C:
+			warned = true
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		t.mu.Lock()
+	}
+
+	if exclusive {
+		l.exclusive = name
+	} else {
+		l.shared[h] = name
+	}
+	t.mu.Unlock()
+
+	if warn != nil {
+		Event(ctx, LockWaitEvent,
+			Attr{Key: "key", Value: key},
+			Attr{Key: "mode", Value: lockMode(exclusive)},
+			Attr{Key: "wait", Value: time.Since(start).String()},
+		)
+	}
+	return nil
+}
+
+func (t *lockTable) release(key string, exclusive bool, h *heldLocks) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.get(key)
+	if exclusive {
+		l.exclusive = ""
+	} else {
+		delete(l.shared, h)
+	}
+	l.notify()
+}
+
+// notify wakes up the tests waiting for the lock
+func (l *resourceLock) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// holders describes the tests holding the lock
+func (l *resourceLock) holders() string {
+	var holders []string
+	if l.exclusive != "" {
+		holders = append(holders, fmt.Sprintf("%s (exclusive)", l.exclusive))
+	}
+	var shared []string
+	for _, name := range l.shared {
+		shared = append(shared, fmt.Sprintf("%s (shared)", name))
+	}
+	slices.Sort(shared)
+	holders = append(holders, shared...)
+	if len(holders) == 0 {
+		return "nobody (queued behind tests waiting for an exclusive lock)"
+	}
+	return strings.Join(holders, ", ")
+}
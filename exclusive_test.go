@@ -0,0 +1,107 @@
+package testctx_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExclusive(t *testing.T) {
+	if inSubprocess() {
+		var mu sync.Mutex
+		var shared, exclusive, maxShared int
+		var violations []string
+
+		enter := func(t *testctx.T, excl bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			if exclusive > 0 || excl && shared > 0 {
+				violations = append(violations, t.Name())
+			}
+			if excl {
+				exclusive++
+			} else {
+				shared++
+				maxShared = max(maxShared, shared)
+			}
+		}
+		leave := func(excl bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			if excl {
+				exclusive--
+			} else {
+				shared--
+			}
+		}
+
+		tt := testctx.New(t, testctx.WithParallel())
+		tt.Cleanup(func() {
+			t.Logf("violations: %v", violations)
+			t.Logf("max shared: %d", maxShared)
+		})
+
+		for _, name := range []string{"reader 1", "writer 1", "reader 2", "writer 2", "reader 3"} {
+			excl := strings.HasPrefix(name, "writer")
+			lock := testctx.WithShared[*testing.T]("db")
+			if excl {
+				lock = testctx.WithExclusive[*testing.T]("db")
+			}
+			tt.Using(lock).Run(name, func(ctx context.Context, t *testctx.T) {
+				enter(t, excl)
+				defer leave(excl)
+				time.Sleep(50 * time.Millisecond)
+			})
+		}
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestExclusive")
+	assert.True(t, passed)
+	assert.Contains(t, out, "violations: []")
+	assert.Regexp(t, `max shared: [23]`, out)
+}
+
+func TestExclusiveNested(t *testing.T) {
+	tt := testctx.New(t, testctx.WithExclusive[*testing.T]("nested", "other"))
+
+	var ran bool
+	tt.Run("parent", func(ctx context.Context, t *testctx.T) {
+		t.Using(testctx.WithShared[*testing.T]("nested")).Run("child", func(ctx context.Context, t *testctx.T) {
+			ran = true
+		})
+	})
+	assert.True(t, ran, "subtests should run under the parent's locks")
+}
+
+func TestExclusiveWait(t *testing.T) {
+	if inSubprocess() {
+		testctx.LockWaitWarning = 20 * time.Millisecond
+		tt := testctx.New(t, testctx.WithTimeout[*testing.T](time.Second))
+
+		tt.Using(testctx.WithShared[*testing.T]("upgrade")).Run("upgrade", func(ctx context.Context, t *testctx.T) {
+			t.Using(testctx.WithExclusive[*testing.T]("upgrade")).Run("child", func(ctx context.Context, t *testctx.T) {})
+		})
+
+		// Pausing inside its body, the holder still has the exclusive lock
+		// when the waiter asks for a shared one
+		tt.Using(testctx.WithExclusive[*testing.T]("wait")).Run("holder", func(ctx context.Context, t *testctx.T) {
+			t.Unwrap().Parallel()
+			time.Sleep(100 * time.Millisecond)
+		})
+		tt.Using(testctx.WithParallel(), testctx.WithShared[*testing.T]("wait")).Run("waiter", func(ctx context.Context, t *testctx.T) {})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestExclusiveWait")
+	assert.False(t, passed)
+	assert.Contains(t, out, `lock on "wait", held by: TestExclusiveWait/holder (exclusive)`)
+	assert.Contains(t, out, "--- PASS: TestExclusiveWait/waiter")
+	assert.Contains(t, out, "--- FAIL: TestExclusiveWait/upgrade/child")
+	assert.Contains(t, out, `cannot lock "upgrade" exclusively while an enclosing test holds a shared lock on it`)
+}
@@ -0,0 +1,92 @@
+package testctx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// WithFailFast creates middleware that stops the subtree of tests it is
+// first applied to (e.g. a RunTests suite) as soon as one of its tests
+// fails: tests that haven't started yet are skipped, and the contexts of
+// tests already running are canceled. Unlike -failfast, this works within a
+// single RunTests call and for parallel subtests that have already started.
+func WithFailFast[T Runner[T]]() Middleware[T] {
+	key := &scopeKey{}
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			s, ok := ctx.Value(key).(*failFast)
+			if !ok {
+				s = &failFast{running: map[*testState]context.CancelCauseFunc{}}
+				next(context.WithValue(ctx, key, s), w)
+				return
+			}
+
+			if first := s.firstFailure(); first != "" {
+				w.Skipf("skipped: fail-fast after %s failed", first)
+			}
+
+			ctx, cancel := context.WithCancelCause(ctx)
+			s.track(w.state, cancel)
+			w.Cleanup(func() {
+				// Catches failures not reported through W, e.g. Fail. Those
+				// recorded by a WithRetry attempt that may still be retried
+				// don't count.
+				if w.recording() == nil && w.tb.Failed() {
+					s.fail(w.state, w.Name())
+				}
+				s.untrack(w.state)
+				cancel(nil)
+			})
+			w.state.onFailure(func(string) {
+				s.fail(w.state, w.Name())
+			})
+			next(ctx, w)
+		}
+	}
+}
+
+// failFast tracks the running tests of a fail-fast subtree
+type failFast struct {
+	mu      sync.Mutex
+	first   string
+	running map[*testState]context.CancelCauseFunc
+}
+
+func (s *failFast) track(state *testState, cancel context.CancelCauseFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[state] = cancel
+}
+
+func (s *failFast) untrack(state *testState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, state)
+}
+
+// firstFailure returns the name of the first test that failed, if any
+func (s *failFast) firstFailure() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.first
+}
+
+// fail records the failure of the named test and, if it is the first,
+// cancels every other running test except its own ancestors, whose
+// cancellation would cancel the failed test too
+func (s *failFast) fail(failed *testState, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.first != "" {
+		return
+	}
+	s.first = name
+
+	cause := fmt.Errorf("fail-fast: %s failed", name)
+	for state, cancel := range s.running {
+		if !state.isAncestorOf(failed) {
+			cancel(cause)
+		}
+	}
+}
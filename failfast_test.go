@@ -0,0 +1,91 @@
+package testctx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+type FailFastSuite struct{}
+
+func (FailFastSuite) TestA(ctx context.Context, t *testctx.T) {
+	t.Run("passes", func(ctx context.Context, t *testctx.T) {})
+	t.Run("fails", func(ctx context.Context, t *testctx.T) {
+		t.Error("boom")
+	})
+	t.Run("after", func(ctx context.Context, t *testctx.T) {})
+}
+
+func (FailFastSuite) TestB(ctx context.Context, t *testctx.T) {}
+
+type ParallelFailFastSuite struct{}
+
+func (ParallelFailFastSuite) TestFails(ctx context.Context, t *testctx.T) {
+	time.Sleep(100 * time.Millisecond)
+	t.Fail()
+}
+
+func (ParallelFailFastSuite) TestInFlight(ctx context.Context, t *testctx.T) {
+	<-ctx.Done()
+	t.Logf("in-flight test canceled: %v", context.Cause(ctx))
+}
+
+func TestFailFast(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t, testctx.WithFailFast[*testing.T]()).RunTests(FailFastSuite{})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestFailFast")
+	assert.False(t, passed)
+	assert.Contains(t, out, "--- PASS: TestFailFast/TestA/passes")
+	assert.Contains(t, out, "--- FAIL: TestFailFast/TestA/fails")
+	assert.Contains(t, out, "--- SKIP: TestFailFast/TestA/after")
+	assert.Contains(t, out, "--- SKIP: TestFailFast/TestB")
+	assert.Contains(t, out, "skipped: fail-fast after TestFailFast/TestA/fails failed")
+}
+
+func TestFailFastParallel(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t,
+			testctx.WithParallel(),
+			testctx.WithFailFast[*testing.T](),
+		).RunTests(ParallelFailFastSuite{})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestFailFastParallel")
+	assert.False(t, passed)
+	assert.Contains(t, out, "in-flight test canceled: fail-fast: TestFailFastParallel/TestFails failed")
+}
+
+type RetryFailFastSuite struct{}
+
+func (RetryFailFastSuite) TestA(ctx context.Context, t *testctx.T) {
+	if testctx.Attempt(ctx) == 1 {
+		t.Error("first try")
+	}
+}
+
+func (RetryFailFastSuite) TestB(ctx context.Context, t *testctx.T) {}
+
+func TestFailFastRetry(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t,
+			testctx.WithRetry[*testing.T](2),
+			testctx.WithFailFast[*testing.T](),
+		).RunTests(RetryFailFastSuite{})
+		return
+	}
+
+	// Failures of attempts that are retried aren't failures of the test
+	out, passed := runSubprocess(t, "TestFailFastRetry")
+	assert.True(t, passed, out)
+	assert.Contains(t, out, "attempt 1/2 failed: first try")
+	assert.Contains(t, out, "--- PASS: TestFailFastRetry/TestA")
+	assert.Contains(t, out, "--- PASS: TestFailFastRetry/TestB")
+	assert.NotContains(t, out, "fail-fast")
+}
@@ -0,0 +1,43 @@
+//go:build !unix
+
+package testctx
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Without file locking, lock files are only locked within this process
+
+var (
+	lockedFilesMu sync.Mutex
+	lockedFiles   = map[string]*os.File{}
+)
+
+func lockFileNonBlocking(f *os.File) (bool, error) {
+	path, err := filepath.Abs(f.Name())
+	if err != nil {
+		return false, err
+	}
+	lockedFilesMu.Lock()
+	defer lockedFilesMu.Unlock()
+	if _, ok := lockedFiles[path]; ok {
+		return false, nil
+	}
+	lockedFiles[path] = f
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	path, err := filepath.Abs(f.Name())
+	if err != nil {
+		return err
+	}
+	lockedFilesMu.Lock()
+	defer lockedFilesMu.Unlock()
+	if lockedFiles[path] == f {
+		delete(lockedFiles, path)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package testctx
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFileNonBlocking takes an exclusive lock on f without blocking,
+// reporting whether it succeeded. The lock is released when f is closed,
+// including when the process exits.
+func lockFileNonBlocking(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,159 @@
+package testctx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// testIDLabel is the pprof label used to attribute goroutines to the test
+// that started them. Goroutines inherit labels from their creator, so every
+// goroutine spawned (directly or indirectly) by a test carries its ID.
+const testIDLabel = "testctx.test.id"
+
+// labelGoroutine tags the calling goroutine with the test's ID
+func (s *testState) labelGoroutine() {
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(),
+		pprof.Labels(testIDLabel, strconv.FormatInt(s.id, 10))))
+}
+
+// goroutineGroup is a set of goroutines sharing the same stack and labels,
+// as reported by the pprof goroutine profile
+type goroutineGroup struct {
+	count  int
+	labels map[string]string
+	// stack is the symbolized stack, one "function\n\tfile:line" per frame
+	stack string
+}
+
+// belongsTo reports whether the goroutines were started by the given test
+func (g goroutineGroup) belongsTo(s *testState) bool {
+	return g.labels[testIDLabel] == strconv.FormatInt(s.id, 10)
+}
+
+func (g goroutineGroup) String() string {
+	return fmt.Sprintf("%d goroutine(s):\n%s", g.count, g.stack)
+}
+
+// goroutineGroups returns all goroutines grouped by stack and labels. Unlike
+// full stack dumps, the pprof goroutine profile includes labels on every Go
+// version, which lets us attribute goroutines to tests.
+func goroutineGroups() []goroutineGroup {
+	// Leave out the calling goroutine, which is busy collecting the profile
+	pc, _, _, _ := runtime.Caller(0)
+	self := runtime.FuncForPC(pc).Name()
+
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+
+	var groups []goroutineGroup
+	for _, block := range strings.Split(buf.String(), "\n\n") {
+		var g goroutineGroup
+		var frames []string
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "# labels: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "# labels: ")), &g.labels)
+			case strings.HasPrefix(line, "#\t"):
+				// #	0x480984	time.Sleep+0x164	/usr/local/go/src/runtime/time.go:368
+				fields := strings.Fields(strings.TrimPrefix(line, "#"))
+				if len(fields) < 3 {
+					continue
+				}
+				fn, _, _ := strings.Cut(fields[1], "+0x")
+				frames = append(frames, fn+"\n\t"+fields[2])
+			case strings.Contains(line, " @ "):
+				g.count, _ = strconv.Atoi(strings.Fields(line)[0])
+			}
+		}
+		g.stack = strings.Join(frames, "\n")
+		if g.count == 0 || strings.Contains(g.stack, self+"\n") {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// testGoroutineDump returns the stacks of the goroutines started by the
+// given test or its running subtests, where a stuck test is often blocked
+func testGoroutineDump(s *testState) string {
+	subtree := liveTests.subtree(s)
+	var stacks []string
+	for _, g := range goroutineGroups() {
+		if g.belongsTo(s) {
+			stacks = append(stacks, g.String())
+		} else if name, ok := subtree[g.labels[testIDLabel]]; ok {
+			stacks = append(stacks, fmt.Sprintf("subtest %s: %s", name, g))
+		}
+	}
+	return strings.Join(stacks, "\n\n")
+}
+
+// currentGoroutineID returns the ID of the calling goroutine
+func currentGoroutineID() int64 {
+	// The trace starts with "goroutine 123 [running]:"
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	fields := strings.Fields(string(buf))
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(fields[1], 10, 64)
+	return id
+}
+
+// creationSites returns where the goroutines in the group were created, by
+// matching them against a full stack dump, which unlike the goroutine
+// profile includes each goroutine's creator
+func (g goroutineGroup) creationSites() []string {
+	var funcs []string
+	for _, frame := range strings.Split(g.stack, "\n") {
+		if !strings.HasPrefix(frame, "\t") {
+			funcs = append(funcs, frame)
+		}
+	}
+
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	var sites []string
+	for _, trace := range strings.Split(string(buf), "\n\n") {
+		var traceFuncs []string
+		var site string
+		lines := strings.Split(trace, "\n")
+		for i, line := range lines {
+			switch {
+			case i == 0, line == "", strings.HasPrefix(line, "\t"):
+				// header, end of the dump, or file:line
+			case strings.HasPrefix(line, "created by "):
+				site = line
+				if i+1 < len(lines) {
+					site += "\n" + lines[i+1]
+				}
+			default:
+				if paren := strings.LastIndex(line, "("); paren > 0 {
+					line = line[:paren]
+				}
+				traceFuncs = append(traceFuncs, line)
+			}
+		}
+		if site != "" && slices.Equal(funcs, traceFuncs) && !slices.Contains(sites, site) {
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
@@ -0,0 +1,211 @@
+package testctx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GoWaitTimeout bounds how long a finished test waits for goroutines
+// started via Go to return. It is subject to the timeout policy; see
+// ScaleTimeout.
+var GoWaitTimeout = time.Minute
+
+// errGroupFinished is the cause of cancellation of goroutines started via
+// Go when the test finishes
+var errGroupFinished = errors.New("test finished")
+
+// goIDLabel is the pprof label identifying goroutines started via Go
+const goIDLabel = "testctx.go.id"
+
+// Go runs fn in a new goroutine tied to the lifecycle of the test, replacing
+// ad-hoc WaitGroups for background work:
+//
+//   - fn receives a context derived from the test context, which is
+//     canceled if any other goroutine started via Go fails, or once the
+//     test finishes
+//   - a non-nil error returned by fn fails the test via Errorf, unless it
+//     merely reports that the context was canceled
+//   - a panic in fn is recovered and fails the test, with its stack
+//   - the test does not complete until every goroutine has returned; those
+//     still running after GoWaitTimeout are reported, with their stacks
+func (w *W[T]) Go(fn func(ctx context.Context) error) {
+	g := w.goroutineGroup()
+
+	site := "unknown location"
+	if _, file, line, ok := runtime.Caller(1); ok {
+		site = fmt.Sprintf("%s:%d", file, line)
+	}
+
+	ctx, cancel := context.WithCancelCause(w.ctx)
+	id := g.add(site, cancel)
+	go func() {
+		defer g.done(id)
+		defer cancel(nil)
+		pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels(
+			testIDLabel, g.testID,
+			goIDLabel, strconv.Itoa(id),
+		)))
+		defer func() {
+			if r := recover(); r != nil {
+				g.fail(fmt.Errorf("panic: %v", r))
+				stack := debug.Stack()
+				g.report(func() {
+					w.Errorf("goroutine started at %s panicked: %v\n\n%s", site, r, stack)
+				})
+			}
+		}()
+		if err := fn(ctx); err != nil {
+			if ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+				return
+			}
+			g.fail(err)
+			g.report(func() {
+				w.Errorf("goroutine started at %s failed: %v", site, err)
+			})
+		}
+	}()
+}
+
+// goroutineGroup returns the test's (or the current attempt's) group of
+// goroutines started via Go, creating it (and registering the cleanup that
+// waits for it) on first use
+func (w *W[T]) goroutineGroup() *goGroup {
+	w.state.mu.Lock()
+	defer w.state.mu.Unlock()
+	group := &w.state.group
+	if w.attempt != nil {
+		// Each attempt of WithRetry waits for its own goroutines
+		group = &w.attempt.group
+	}
+	if *group != nil {
+		return *group
+	}
+	g := &goGroup{
+		testID:  strconv.FormatInt(w.state.id, 10),
+		running: map[int]goRoutine{},
+	}
+	*group = g
+	w.Cleanup(func() {
+		timeout := ScaleTimeout(GoWaitTimeout)
+		if stuck := g.wait(timeout); stuck != "" {
+			w.Errorf("goroutines started via Go did not return within %s of the test finishing:\n\n%s", timeout, stuck)
+		}
+	})
+	return g
+}
+
+// goGroup tracks the goroutines started via Go for a test
+type goGroup struct {
+	testID string
+
+	mu      sync.Mutex
+	lastID  int
+	running map[int]goRoutine
+	failed  bool
+	allDone chan struct{}
+
+	// reportMu is held while reporting on the test, rather than mu, since
+	// reports run the test's loggers and failure hooks
+	reportMu  sync.Mutex
+	abandoned bool
+}
+
+type goRoutine struct {
+	site   string
+	cancel context.CancelCauseFunc
+}
+
+func (g *goGroup) add(site string, cancel context.CancelCauseFunc) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lastID++
+	g.running[g.lastID] = goRoutine{site: site, cancel: cancel}
+	return g.lastID
+}
+
+func (g *goGroup) done(id int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.running, id)
+	if len(g.running) == 0 && g.allDone != nil {
+		close(g.allDone)
+		g.allDone = nil
+	}
+}
+
+// fail cancels the other goroutines in the group after the first failure
+func (g *goGroup) fail(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.failed {
+		return
+	}
+	g.failed = true
+	for _, r := range g.running {
+		r.cancel(err)
+	}
+}
+
+// report calls fn to report on the test, unless the group was abandoned, in
+// which case the test may have completed already
+func (g *goGroup) report(fn func()) {
+	g.reportMu.Lock()
+	defer g.reportMu.Unlock()
+	if !g.abandoned {
+		fn()
+	}
+}
+
+// wait cancels all goroutines and waits for them to return. If some are
+// still running after the timeout, they are abandoned and a description of
+// them is returned.
+func (g *goGroup) wait(timeout time.Duration) string {
+	g.mu.Lock()
+	if len(g.running) == 0 {
+		g.mu.Unlock()
+		return ""
+	}
+	allDone := make(chan struct{})
+	g.allDone = allDone
+	for _, r := range g.running {
+		r.cancel(errGroupFinished)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-allDone:
+		return ""
+	case <-time.After(timeout):
+	}
+
+	// Wait for reports in progress, which the test can't outlive
+	g.reportMu.Lock()
+	g.abandoned = true
+	g.reportMu.Unlock()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stacks := map[string][]string{}
+	for _, grp := range goroutineGroups() {
+		if id := grp.labels[goIDLabel]; id != "" && grp.labels[testIDLabel] == g.testID {
+			stacks[id] = append(stacks[id], grp.stack)
+		}
+	}
+	var reports []string
+	for _, id := range slices.Sorted(maps.Keys(g.running)) {
+		reports = append(reports, fmt.Sprintf("goroutine started at %s:\n%s",
+			g.running[id].site, strings.Join(stacks[strconv.Itoa(id)], "\n")))
+	}
+	return strings.Join(reports, "\n\n")
+}
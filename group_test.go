@@ -0,0 +1,74 @@
+package testctx_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGo(t *testing.T) {
+	var finished atomic.Bool
+
+	testctx.New(t).Run("background", func(ctx context.Context, t *testctx.T) {
+		t.Go(func(ctx context.Context) error {
+			// Runs until the test finishes
+			<-ctx.Done()
+			finished.Store(true)
+			return ctx.Err()
+		})
+	})
+
+	assert.True(t, finished.Load(), "test should wait for goroutines to return")
+}
+
+func TestGoFailures(t *testing.T) {
+	if inSubprocess() {
+		testctx.GoWaitTimeout = 100 * time.Millisecond
+		tt := testctx.New(t)
+
+		tt.Run("error", func(ctx context.Context, t *testctx.T) {
+			canceled := make(chan struct{})
+			t.Go(func(ctx context.Context) error {
+				defer close(canceled)
+				<-ctx.Done()
+				t.Logf("sibling canceled: %v", context.Cause(ctx))
+				return ctx.Err()
+			})
+			t.Go(func(ctx context.Context) error {
+				return errors.New("boom")
+			})
+			<-canceled
+		})
+
+		tt.Run("panic", func(ctx context.Context, t *testctx.T) {
+			t.Go(func(ctx context.Context) error {
+				panic("oh no")
+			})
+		})
+
+		tt.Run("stuck", func(ctx context.Context, t *testctx.T) {
+			t.Go(func(ctx context.Context) error {
+				blockForever()
+				return nil
+			})
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestGoFailures")
+	assert.False(t, passed)
+	assert.Regexp(t, `goroutine started at \S+group_test.go:\d+ failed: boom`, out)
+	assert.Contains(t, out, "sibling canceled: boom")
+	assert.Regexp(t, `goroutine started at \S+group_test.go:\d+ panicked: oh no`, out)
+	assert.Contains(t, out, "goroutines started via Go did not return within "+
+		testctx.ScaleTimeout(100*time.Millisecond).String())
+	assert.Contains(t, out, "blockForever")
+	assert.Contains(t, out, "--- FAIL: TestGoFailures/error")
+	assert.Contains(t, out, "--- FAIL: TestGoFailures/panic")
+	assert.Contains(t, out, "--- FAIL: TestGoFailures/stuck")
+}
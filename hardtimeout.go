@@ -0,0 +1,78 @@
+package testctx
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// WithHardTimeout creates middleware that enforces a timeout even when the
+// code under test ignores context cancellation. Like WithTimeout, the test
+// context is canceled after d. If the test function still hasn't returned
+// after a further grace period, the stacks of the test's goroutines are
+// dumped to the test log (and any loggers), the test is marked failed, and
+// it is abandoned so that the rest of the test binary can carry on.
+//
+// The test function runs on a separate goroutine so that it can be
+// abandoned. Once abandoned, calls it makes through *W are dropped, but
+// calls made directly on the underlying test (via Unwrap) are not. Place
+// WithParallel before WithHardTimeout so that time spent waiting to run in
+// parallel doesn't count towards the timeout. Like WithTimeout, d is
+// subject to the timeout policy; see ScaleTimeout and TimeoutOverrider.
+func WithHardTimeout[T Runner[T]](d, grace time.Duration) Middleware[T] {
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			ctx, d := effectiveTimeout(ctx, w, d)
+			ctx, cancel := context.WithTimeout(ctx, d)
+			w.Cleanup(cancel)
+
+			body := w.clone()
+			body.detached = new(atomic.Bool)
+
+			done := make(chan struct{})
+			panicked := make(chan *goroutinePanic, 1)
+			go func() {
+				defer close(done)
+				defer func() {
+					if r := recover(); r != nil {
+						panicked <- &goroutinePanic{value: r, stack: debug.Stack()}
+					}
+				}()
+				next(ctx, body)
+			}()
+
+			timer := time.NewTimer(d + grace)
+			defer timer.Stop()
+
+			select {
+			case <-done:
+				select {
+				case p := <-panicked:
+					// Re-panic on the test goroutine so that the panic is
+					// attributed to this test, as it would be without us
+					panic(p)
+				default:
+				}
+				return
+			case <-timer.C:
+			}
+
+			w.Errorf("test did not return within hard timeout of %s (+%s grace); goroutines:\n\n%s",
+				d, grace, testGoroutineDump(w.state))
+			body.detached.Store(true)
+		}
+	}
+}
+
+// goroutinePanic carries a panic recovered on one goroutine so that it can
+// be re-raised on another without losing the original stack
+type goroutinePanic struct {
+	value any
+	stack []byte
+}
+
+func (p *goroutinePanic) String() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
@@ -0,0 +1,95 @@
+package testctx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHardTimeout(t *testing.T) {
+	if inSubprocess() {
+		tt := testctx.New(t, testctx.WithHardTimeout[*testing.T](100*time.Millisecond, 100*time.Millisecond))
+		tt.Run("stuck", func(ctx context.Context, t *testctx.T) {
+			blockForever()
+		})
+		tt.Run("next", func(ctx context.Context, t *testctx.T) {
+			t.Log("still running")
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestHardTimeout")
+	assert.False(t, passed)
+	assert.Contains(t, out, "--- FAIL: TestHardTimeout/stuck")
+	assert.Contains(t, out, "did not return within hard timeout of "+
+		testctx.ScaleTimeout(100*time.Millisecond).String()+" (+100ms grace)")
+	assert.Contains(t, out, "blockForever")
+	assert.Contains(t, out, "still running")
+	assert.Contains(t, out, "--- PASS: TestHardTimeout/next")
+}
+
+func TestHardTimeoutSubtest(t *testing.T) {
+	if inSubprocess() {
+		hardTimeout := testctx.WithHardTimeout[*testing.T](100*time.Millisecond, 100*time.Millisecond)
+		tt := testctx.New(t, func(next testctx.RunFunc[*testing.T]) testctx.RunFunc[*testing.T] {
+			// Only the parent has a hard timeout
+			timed := hardTimeout(next)
+			return func(ctx context.Context, w *testctx.W[*testing.T]) {
+				if w.BaseName() == "parent" {
+					timed(ctx, w)
+				} else {
+					next(ctx, w)
+				}
+			}
+		})
+		tt.Run("parent", func(ctx context.Context, t *testctx.T) {
+			t.Run("child", func(ctx context.Context, t *testctx.T) {
+				blockForever()
+			})
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestHardTimeoutSubtest")
+	assert.False(t, passed)
+	assert.Contains(t, out, "subtest TestHardTimeoutSubtest/parent/child: 1 goroutine(s)")
+	assert.Contains(t, out, "blockForever")
+}
+
+func TestHardTimeoutAbandoned(t *testing.T) {
+	if inSubprocess() {
+		tt := testctx.New(t, testctx.WithHardTimeout[*testing.T](100*time.Millisecond, 100*time.Millisecond))
+		abandoned := make(chan bool)
+		tt.Run("slow", func(ctx context.Context, t *testctx.T) {
+			<-ctx.Done()
+			time.Sleep(500 * time.Millisecond)
+			// The test has completed by now, so these are dropped instead of
+			// panicking
+			t.Cleanup(func() {})
+			abandoned <- !t.Run("late", func(ctx context.Context, t *testctx.T) {})
+		})
+		t.Logf("late subtest skipped: %v", <-abandoned)
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestHardTimeoutAbandoned")
+	assert.False(t, passed)
+	assert.Contains(t, out, "--- FAIL: TestHardTimeoutAbandoned/slow")
+	assert.Contains(t, out, "late subtest skipped: true")
+	assert.NotContains(t, out, "panic:")
+}
+
+func TestHardTimeoutPasses(t *testing.T) {
+	tt := testctx.New(t, testctx.WithHardTimeout[*testing.T](time.Second, time.Second))
+	tt.Run("fast", func(ctx context.Context, t *testctx.T) {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+	})
+}
+
+func blockForever() {
+	select {}
+}
@@ -0,0 +1,170 @@
+package testctx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// HandleInterrupts controls whether the test binary handles SIGINT and
+// SIGTERM. When enabled, the first signal cancels the context of every test
+// created via New (with an ErrInterrupted cause), marks the running tests
+// failed and reports them, and gives cleanup InterruptGracePeriod to finish
+// before the process exits. A second signal exits immediately. Set it to
+// false in TestMain, before any test runs, to leave signal handling alone.
+var HandleInterrupts = true
+
+// InterruptGracePeriod is how long tests get to clean up after an interrupt
+// before the process exits
+var InterruptGracePeriod = 10 * time.Second
+
+// ErrInterrupted is the cause of test context cancellation when the test
+// binary receives SIGINT or SIGTERM
+var ErrInterrupted = errors.New("test binary interrupted")
+
+// rootContext returns the context that all test contexts derive from,
+// starting signal handling on first use
+var rootContext = sync.OnceValue(func() context.Context {
+	if !HandleInterrupts {
+		return context.Background()
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go handleInterrupts(sigs, cancel)
+	return ctx
+})
+
+func handleInterrupts(sigs <-chan os.Signal, cancel context.CancelCauseFunc) {
+	sig := <-sigs
+	cause := fmt.Errorf("%w: received %s", ErrInterrupted, sig)
+	interrupted := liveTests.fail(cause)
+	cancel(cause)
+	fmt.Fprintf(os.Stderr, "testctx: received %s; canceled running tests, waiting up to %s for cleanup (repeat to exit now)\n",
+		sig, InterruptGracePeriod)
+	if len(interrupted) > 0 {
+		fmt.Fprintf(os.Stderr, "testctx: interrupted tests:\n\t%s\n", strings.Join(interrupted, "\n\t"))
+	}
+
+	select {
+	case <-sigs:
+	case <-time.After(InterruptGracePeriod):
+	}
+	if running := liveTests.names(); len(running) > 0 {
+		fmt.Fprintf(os.Stderr, "testctx: tests still running at exit:\n\t%s\n", strings.Join(running, "\n\t"))
+	}
+	os.Exit(1)
+}
+
+// liveTests tracks every test that is currently running
+var liveTests = &testRegistry{tests: map[*testState]*liveTest{}}
+
+// testRegistry is a set of running tests
+type testRegistry struct {
+	mu    sync.Mutex
+	tests map[*testState]*liveTest
+}
+
+// liveTest is a running test
+type liveTest struct {
+	tb testing.TB
+
+	mu sync.Mutex
+	// fail reports a failure of the test
+	fail func(err error)
+	// done is set once the test's cleanup functions run, after which it
+	// can no longer fail
+	done bool
+}
+
+// track records the test as running until it completes
+func (r *testRegistry) track(t testing.TB, s *testState) {
+	test := &liveTest{tb: t, fail: func(err error) { t.Error(err) }}
+	r.mu.Lock()
+	r.tests[s] = test
+	r.mu.Unlock()
+	t.Cleanup(func() {
+		r.mu.Lock()
+		delete(r.tests, s)
+		r.mu.Unlock()
+		test.mu.Lock()
+		test.done = true
+		test.mu.Unlock()
+	})
+}
+
+// reportVia makes the test report failures through w, so that they are
+// redacted and reach its loggers and failure hooks like any other failure
+func reportVia[T Runner[T]](w *W[T]) {
+	liveTests.mu.Lock()
+	test, ok := liveTests.tests[w.state]
+	liveTests.mu.Unlock()
+	if ok {
+		test.mu.Lock()
+		test.fail = func(err error) { w.Error(err) }
+		test.mu.Unlock()
+	}
+}
+
+// subtree returns the names of s and its running subtests, keyed by test
+// ID as in goroutine labels
+func (r *testRegistry) subtree(s *testState) map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := map[string]string{}
+	for state, test := range r.tests {
+		if s.isAncestorOf(state) {
+			names[strconv.FormatInt(state.id, 10)] = test.tb.Name()
+		}
+	}
+	return names
+}
+
+// names returns the sorted names of the running tests
+func (r *testRegistry) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.namesLocked()
+}
+
+// fail marks every running test failed with the given error, and returns
+// their sorted names
+func (r *testRegistry) fail(err error) []string {
+	r.mu.Lock()
+	tests := make([]*liveTest, 0, len(r.tests))
+	for _, test := range r.tests {
+		tests = append(tests, test)
+	}
+	names := r.namesLocked()
+	r.mu.Unlock()
+
+	// Failing a test runs its loggers and failure hooks, which may start
+	// or finish other tests, so only the test itself is locked: that's
+	// enough to keep it from completing, since its cleanup waits for us
+	for _, test := range tests {
+		test.mu.Lock()
+		if !test.done {
+			test.fail(err)
+		}
+		test.mu.Unlock()
+	}
+	return names
+}
+
+func (r *testRegistry) namesLocked() []string {
+	var names []string
+	for _, test := range r.tests {
+		names = append(names, test.tb.Name())
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,51 @@
+package testctx_test
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending os.Interrupt is not supported on Windows")
+	}
+
+	if inSubprocess() {
+		testctx.InterruptGracePeriod = 100 * time.Millisecond
+		rec := testctx.NewLogRecorder()
+		tt := testctx.New(t, testctx.WithLogRecorder[*testing.T](rec))
+		tt.Redact("binary")
+		tt.Run("long-running", func(ctx context.Context, t *testctx.T) {
+			t.Cleanup(func() {
+				t.Logf("recorded: %v", rec.Messages(testctx.LogKindError))
+				os.Stderr.WriteString("cleanup ran\n")
+			})
+
+			proc, err := os.FindProcess(os.Getpid())
+			if err != nil {
+				t.Fatal(err)
+			}
+			proc.Signal(os.Interrupt)
+
+			<-ctx.Done()
+			t.Logf("canceled: %v", context.Cause(ctx))
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestInterrupt")
+	assert.False(t, passed)
+	assert.Contains(t, out, "testctx: received interrupt")
+	assert.Contains(t, out, "testctx: interrupted tests:\n\tTestInterrupt\n\tTestInterrupt/long-running\n")
+	assert.Contains(t, out, "canceled: test *** interrupted: received interrupt")
+	assert.Contains(t, out, "cleanup ran")
+	// The failure goes through the test's loggers, redacted
+	assert.Contains(t, out, "recorded: [test *** interrupted: received interrupt]")
+	assert.NotContains(t, out, "test binary interrupted")
+}
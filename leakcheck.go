@@ -0,0 +1,112 @@
+package testctx
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultLeakIgnores lists functions of well-known background goroutines
+// that may be started lazily during a test and legitimately outlive it,
+// such as the OpenTelemetry batch processors set up by oteltest.Main
+var DefaultLeakIgnores = []string{
+	"go.opentelemetry.io/otel/sdk/trace.(*batchSpanProcessor).processQueue",
+	"go.opentelemetry.io/otel/sdk/log.(*BatchProcessor).poll",
+	"go.opentelemetry.io/otel/sdk/metric.(*PeriodicReader).run",
+	"google.golang.org/grpc.",
+	"net/http.(*persistConn).readLoop",
+	"net/http.(*persistConn).writeLoop",
+}
+
+// LeakCheckConfig holds configuration for the WithLeakCheck middleware
+type LeakCheckConfig struct {
+	// Ignore lists function names (or prefixes of them); goroutines with
+	// any matching frame in their stack are not reported. Defaults to
+	// DefaultLeakIgnores.
+	Ignore []string
+	// Timeout is how long to wait for goroutines to exit after the test
+	// finishes before reporting them. Defaults to one second, and is
+	// subject to the timeout policy; see ScaleTimeout.
+	Timeout time.Duration
+}
+
+// errTestFinished is the cause of test context cancellation when the test
+// has finished
+var errTestFinished = errors.New("test finished")
+
+// WithLeakCheck creates middleware that fails tests which leak goroutines.
+// After the test and its cleanups have finished (and its context has been
+// canceled), any goroutine that the test started and that is still running
+// is reported along with its stack and where it was created.
+//
+// Goroutines are attributed to tests via pprof labels, which goroutines
+// inherit from their creator, so the check works for parallel tests too.
+// Goroutines whose labels are replaced (e.g. started within pprof.Do with a
+// fresh context) escape attribution.
+func WithLeakCheck[T Runner[T]](cfg ...LeakCheckConfig) Middleware[T] {
+	var c LeakCheckConfig
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	if c.Ignore == nil {
+		c.Ignore = DefaultLeakIgnores
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = time.Second
+	}
+
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			// Cleanups run last-in, first-out, so by the time this one runs
+			// the test's own cleanups have had their chance to stop the
+			// goroutines it started
+			w.Cleanup(func() {
+				// Let goroutines waiting on the test context exit; during a
+				// WithRetry attempt, only the attempt's context is done
+				w.cancel(errTestFinished)
+
+				deadline := time.Now().Add(ScaleTimeout(c.Timeout))
+				for {
+					leaked := leakedGoroutines(w.state, c.Ignore)
+					if len(leaked) == 0 {
+						return
+					}
+					if time.Now().After(deadline) {
+						var reports []string
+						for _, g := range leaked {
+							report := g.String()
+							if sites := g.creationSites(); len(sites) > 0 {
+								report += "\n" + strings.Join(sites, "\n")
+							}
+							reports = append(reports, report)
+						}
+						w.Errorf("test leaked goroutines:\n\n%s", strings.Join(reports, "\n\n"))
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			})
+			next(ctx, w)
+		}
+	}
+}
+
+// leakedGoroutines returns the goroutines started by the test that are
+// still running, excluding ignored ones
+func leakedGoroutines(s *testState, ignore []string) []goroutineGroup {
+	var leaked []goroutineGroup
+	for _, g := range goroutineGroups() {
+		if !g.belongsTo(s) {
+			continue
+		}
+		if slices.ContainsFunc(ignore, func(fn string) bool {
+			return strings.HasPrefix(g.stack, fn) || strings.Contains(g.stack, "\n"+fn)
+		}) {
+			continue
+		}
+		leaked = append(leaked, g)
+	}
+	return leaked
+}
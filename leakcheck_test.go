@@ -0,0 +1,77 @@
+package testctx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeakCheck(t *testing.T) {
+	if inSubprocess() {
+		tt := testctx.New(t,
+			testctx.WithParallel(),
+			testctx.WithLeakCheck[*testing.T](testctx.LeakCheckConfig{
+				Ignore:  []string{"github.com/dagger/testctx_test.ignoredLeak"},
+				Timeout: 100 * time.Millisecond,
+			}))
+
+		tt.Run("leaks", func(ctx context.Context, t *testctx.T) {
+			go blockForever()
+		})
+
+		tt.Run("waits on context", func(ctx context.Context, t *testctx.T) {
+			go func() { <-ctx.Done() }()
+		})
+
+		tt.Run("stops in cleanup", func(ctx context.Context, t *testctx.T) {
+			stop := make(chan struct{})
+			go func() { <-stop }()
+			t.Cleanup(func() { close(stop) })
+		})
+
+		tt.Run("ignored", func(ctx context.Context, t *testctx.T) {
+			go ignoredLeak()
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestLeakCheck")
+	assert.False(t, passed)
+	assert.Contains(t, out, "--- FAIL: TestLeakCheck/leaks")
+	assert.Contains(t, out, "test leaked goroutines")
+	assert.Contains(t, out, "blockForever")
+	assert.Regexp(t, `created by \S+TestLeakCheck\S* in goroutine \d+\n\s+\S+leakcheck_test.go:\d+`, out)
+	assert.Contains(t, out, "--- PASS: TestLeakCheck/waits_on_context")
+	assert.Contains(t, out, "--- PASS: TestLeakCheck/stops_in_cleanup")
+	assert.Contains(t, out, "--- PASS: TestLeakCheck/ignored")
+}
+
+func ignoredLeak() {
+	blockForever()
+}
+
+func TestLeakCheckRetry(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+	tt := testctx.New(t,
+		testctx.WithLogRecorder[*testing.T](rec),
+		testctx.WithRetry[*testing.T](2),
+		testctx.WithLeakCheck[*testing.T](testctx.LeakCheckConfig{Timeout: 100 * time.Millisecond}),
+	)
+
+	var causes []error
+	passed := tt.Run("flaky", func(ctx context.Context, t *testctx.T) {
+		causes = append(causes, context.Cause(ctx))
+		go func() { <-ctx.Done() }()
+		if testctx.Attempt(ctx) == 1 {
+			t.Error("first try")
+		}
+	})
+
+	// Checking the first attempt leaves the next one a live context
+	assert.True(t, passed)
+	assert.Equal(t, []error{nil, nil}, causes)
+	assert.False(t, rec.Contains("leaked"))
+}
@@ -0,0 +1,155 @@
+package testctx
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogKind identifies which kind of Logger call produced a LogEntry
+type LogKind int
+
+const (
+	// LogKindLog is produced by Log and Logf (and Skip, Skipf via W)
+	LogKindLog LogKind = iota
+	// LogKindError is produced by Error and Errorf (and Fatal, Fatalf via W)
+	LogKindError
+)
+
+// String returns a human-readable name for the kind
+func (k LogKind) String() string {
+	switch k {
+	case LogKindLog:
+		return "log"
+	case LogKindError:
+		return "error"
+	default:
+		return fmt.Sprintf("LogKind(%d)", int(k))
+	}
+}
+
+// LogEntry is a single message captured by a LogRecorder
+type LogEntry struct {
+	Kind    LogKind
+	Message string
+	Time    time.Time
+}
+
+// LogRecorder is a Logger that stores every message it receives so that
+// tests can assert on what was logged. It is safe for concurrent use, and
+// its zero value is ready to use.
+type LogRecorder struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+var _ Logger = (*LogRecorder)(nil)
+
+// NewLogRecorder creates an empty LogRecorder
+func NewLogRecorder() *LogRecorder {
+	return &LogRecorder{}
+}
+
+// WithLogRecorder creates middleware that attaches the recorder to each
+// test/benchmark via WithLogger. Applying it more than once to the same
+// subtree records each message only once.
+func WithLogRecorder[T Runner[T]](r *LogRecorder) Middleware[T] {
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			if !slices.Contains(w.loggers, Logger(r)) {
+				w = w.WithLogger(r)
+			}
+			next(ctx, w)
+		}
+	}
+}
+
+// Log records a LogKindLog entry
+func (r *LogRecorder) Log(args ...any) {
+	r.record(LogKindLog, sprintln(args...))
+}
+
+// Logf records a formatted LogKindLog entry
+func (r *LogRecorder) Logf(format string, args ...any) {
+	r.record(LogKindLog, fmt.Sprintf(format, args...))
+}
+
+// Error records a LogKindError entry
+func (r *LogRecorder) Error(args ...any) {
+	r.record(LogKindError, sprintln(args...))
+}
+
+// Errorf records a formatted LogKindError entry
+func (r *LogRecorder) Errorf(format string, args ...any) {
+	r.record(LogKindError, fmt.Sprintf(format, args...))
+}
+
+func (r *LogRecorder) record(kind LogKind, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, LogEntry{
+		Kind:    kind,
+		Message: msg,
+		Time:    time.Now(),
+	})
+}
+
+// Entries returns a copy of all recorded entries, in order
+func (r *LogRecorder) Entries() []LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.entries)
+}
+
+// Messages returns the recorded messages, optionally limited to the given kinds
+func (r *LogRecorder) Messages(kinds ...LogKind) []string {
+	var msgs []string
+	for _, e := range r.Entries() {
+		if len(kinds) == 0 || slices.Contains(kinds, e.Kind) {
+			msgs = append(msgs, e.Message)
+		}
+	}
+	return msgs
+}
+
+// Contains reports whether any recorded message contains substr
+func (r *LogRecorder) Contains(substr string) bool {
+	return slices.ContainsFunc(r.Entries(), func(e LogEntry) bool {
+		return strings.Contains(e.Message, substr)
+	})
+}
+
+// Count returns the number of recorded entries of the given kind
+func (r *LogRecorder) Count(kind LogKind) int {
+	var n int
+	for _, e := range r.Entries() {
+		if e.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Match returns the recorded entries whose message matches the regular
+// expression pattern. It panics if the pattern does not compile.
+func (r *LogRecorder) Match(pattern string) []LogEntry {
+	re := regexp.MustCompile(pattern)
+	var matched []LogEntry
+	for _, e := range r.Entries() {
+		if re.MatchString(e.Message) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// Reset discards all recorded entries
+func (r *LogRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
@@ -0,0 +1,44 @@
+package testctx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogRecorder(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+
+	tt := testctx.New(t, testctx.WithLogRecorder[*testing.T](rec))
+
+	tt.Run("logs", func(ctx context.Context, t *testctx.T) {
+		t.Log("hello", "world")
+		t.Logf("answer: %d", 42)
+
+		t.Run("child", func(ctx context.Context, t *testctx.T) {
+			t.Log("from child")
+		})
+	})
+
+	assert.Equal(t, []string{"hello world", "answer: 42", "from child"}, rec.Messages())
+	assert.Equal(t, 3, rec.Count(testctx.LogKindLog))
+	assert.Equal(t, 0, rec.Count(testctx.LogKindError))
+	assert.True(t, rec.Contains("world"))
+	assert.False(t, rec.Contains("nope"))
+	assert.Len(t, rec.Match(`^answer: \d+$`), 1)
+
+	rec.Reset()
+	assert.Empty(t, rec.Entries())
+}
+
+func TestLogRecorderKinds(t *testing.T) {
+	rec := &testctx.LogRecorder{}
+	rec.Log("log")
+	rec.Errorf("error %d", 1)
+	rec.Error("error", 2)
+
+	assert.Equal(t, []string{"error 1", "error 2"}, rec.Messages(testctx.LogKindError))
+	assert.Equal(t, []string{"log"}, rec.Messages(testctx.LogKindLog))
+}
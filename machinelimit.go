@@ -0,0 +1,231 @@
+package testctx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LockDirEnv is the environment variable that overrides the directory
+// holding the lock files used to coordinate test binaries
+const LockDirEnv = "TESTCTX_LOCK_DIR"
+
+// LockDir returns the directory holding the lock files used to coordinate
+// test binaries: $TESTCTX_LOCK_DIR if set, or testctx-locks-<uid> in the
+// temporary directory. Test binaries only coordinate with those of the same
+// user, since the directory and its lock files are private to its owner.
+func LockDir() string {
+	if dir := os.Getenv(LockDirEnv); dir != "" {
+		return dir
+	}
+	name := "testctx-locks"
+	// Getuid is -1 on Windows, whose temporary directory is per-user
+	if uid := os.Getuid(); uid >= 0 {
+		name += "-" + strconv.Itoa(uid)
+	}
+	return filepath.Join(os.TempDir(), name)
+}
+
+// makeLockDir creates the directory elem names within LockDir, returning
+// its path
+func makeLockDir(elem ...string) (string, error) {
+	dir := filepath.Join(append([]string{LockDir()}, elem...)...)
+	return dir, os.MkdirAll(dir, 0o700)
+}
+
+// lockPollInterval is how often tests waiting for a lock file try again
+const lockPollInterval = 50 * time.Millisecond
+
+// WithMachineLimit creates middleware that allows at most n tests using the
+// named resource to run at once across every test binary on the machine,
+// such as the package test binaries started in parallel by go test ./....
+// Slots are lock files in LockDir, which the operating system unlocks when
+// the process holding them exits, so slots held by crashed or killed test
+// binaries are reclaimed automatically.
+//
+// Like WithConcurrencyLimit, tests block until a slot frees up, or fail if
+// their context is done first, and subtests run within their parent's slot.
+// Tests that wait for more than LockWaitWarning log which processes hold the
+// slots, and tests that had to wait emit a ConcurrencyWaitEvent. Every
+// binary should use the same n for a resource. Place WithParallel before
+// it; see WithParallel.
+//
+// On systems without file locking (other than Unix), slots only limit tests
+// within the same test binary.
+func WithMachineLimit[T Runner[T]](resource string, n int) Middleware[T] {
+	if n < 1 {
+		n = 1
+	}
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			key := machineLimitKey{resource}
+			if held, ok := ctx.Value(key).(*machineLimitHold); ok && held.held.Load() {
+				// An enclosing test is holding a slot for us
+				next(ctx, w)
+				return
+			}
+
+			start := time.Now()
+			slot, waited, err := acquireMachineSlot(ctx, resource, n, w.Name(), w.Logf)
+			if err != nil {
+				w.Fatalf("failed to acquire a slot for %q after %s: %v",
+					resource, time.Since(start).Round(time.Millisecond), err)
+			}
+			if waited {
+				wait := time.Since(start)
+				w.Logf("acquired a slot for %q after %s", resource, wait.Round(time.Millisecond))
+				Event(ctx, ConcurrencyWaitEvent,
+					Attr{Key: "group", Value: resource},
+					Attr{Key: "limit", Value: strconv.Itoa(n)},
+					Attr{Key: "wait", Value: wait.String()},
+					Attr{Key: "scope", Value: "machine"},
+				)
+			}
+
+			hold := &machineLimitHold{}
+			hold.held.Store(true)
+			defer func() {
+				hold.held.Store(false)
+				slot.unlock()
+			}()
+			next(context.WithValue(ctx, key, hold), w)
+		}
+	}
+}
+
+// machineLimitKey is the context key marking a slot held for a resource
+type machineLimitKey struct{ resource string }
+
+// machineLimitHold marks a slot held by a test while its body runs
+type machineLimitHold struct {
+	held atomic.Bool
+}
+
+// unsafeFileChars matches characters not to be used in lock file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// acquireMachineSlot blocks until one of the n lock files for the resource
+// is locked or ctx is done, reporting whether it had to wait. name
+// identifies the holder in diagnostics, which are logged via logf.
+func acquireMachineSlot(ctx context.Context, resource string, n int, name string, logf func(format string, args ...any)) (*lockFile, bool, error) {
+	dir, err := makeLockDir()
+	if err != nil {
+		return nil, false, err
+	}
+	base := filepath.Join(dir, unsafeFileChars.ReplaceAllString(resource, "_"))
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("%s.%d.lock", base, i)
+	}
+
+	start := time.Now()
+	poll := time.NewTicker(lockPollInterval)
+	defer poll.Stop()
+	warn := time.NewTicker(LockWaitWarning)
+	defer warn.Stop()
+	for waited := false; ; waited = true {
+		for _, path := range paths {
+			lf, err := tryLockFile(path, name)
+			if err != nil {
+				return nil, waited, err
+			}
+			if lf != nil {
+				return lf, waited, nil
+			}
+		}
+		if !waited {
+			logf("waiting for a slot for %q (limit %d)", resource, n)
+		}
+
+		select {
+		case <-poll.C:
+		case <-warn.C:
+			logf("waiting %s for a slot for %q (limit %d), held by: %s",
+				time.Since(start).Round(time.Millisecond), resource, n, lockFileHolders(paths))
+		case <-ctx.Done():
+			return nil, true, context.Cause(ctx)
+		}
+	}
+}
+
+// tryLockFile tries to lock the file at path without blocking, returning
+// nil if it is locked by someone else. Once locked, the file records the
+// holder for diagnostics.
+func tryLockFile(path, name string) (*lockFile, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := lockFileNonBlocking(f)
+		if err != nil || !ok {
+			f.Close()
+			return nil, err
+		}
+		if removed(f, path) {
+			// Released and removed by its previous holder between opening
+			// and locking it; locking it now would leave the path free for
+			// someone else, so start over with a fresh file
+			f.Close()
+			continue
+		}
+		// Best effort: the holder is only used in diagnostics
+		if err := f.Truncate(0); err == nil {
+			fmt.Fprintf(f, "pid %d: %s\n", os.Getpid(), name)
+		}
+		return &lockFile{f: f, path: path}, nil
+	}
+}
+
+// removed reports whether path no longer refers to the open file f
+func removed(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(path)
+	return err != nil || !os.SameFile(fi, pi)
+}
+
+// lockFile is a locked lock file
+type lockFile struct {
+	f    *os.File
+	path string
+}
+
+func (l *lockFile) unlock() {
+	// Clear the holder before closing, which releases the lock
+	l.f.Truncate(0)
+	unlockFile(l.f)
+	l.f.Close()
+}
+
+// release removes the lock file and unlocks it, for lock files that are
+// not reused, such as port reservations. Removing it while still locked
+// means anyone who opened it in the meantime notices (see tryLockFile).
+func (l *lockFile) release() {
+	// Best effort: e.g. Windows can't remove open files
+	os.Remove(l.path)
+	l.unlock()
+}
+
+// lockFileHolders describes the holders recorded in the lock files
+func lockFileHolders(paths []string) string {
+	var holders []string
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if holder := strings.TrimSpace(string(content)); err == nil && holder != "" {
+			holders = append(holders, holder)
+		}
+	}
+	if len(holders) == 0 {
+		return "unknown"
+	}
+	return strings.Join(holders, ", ")
+}
@@ -0,0 +1,130 @@
+package testctx_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachineLimit(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t, testctx.WithMachineLimit[*testing.T]("engine", 1)).
+			Run("engine", func(ctx context.Context, t *testctx.T) {
+				// Fails if another process is holding the slot too
+				busy := filepath.Join(os.Getenv("TESTCTX_TEST_DIR"), "busy")
+				f, err := os.OpenFile(busy, os.O_CREATE|os.O_EXCL, 0o666)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.Close()
+				time.Sleep(200 * time.Millisecond)
+				os.Remove(busy)
+			})
+		return
+	}
+
+	dir := t.TempDir()
+	env := []string{testctx.LockDirEnv + "=" + dir, "TESTCTX_TEST_DIR=" + dir}
+
+	var wg sync.WaitGroup
+	outs := make([]string, 2)
+	passed := make([]bool, 2)
+	errs := make([]error, 2)
+	for i := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outs[i], passed[i], errs[i] = startSubprocess("TestMachineLimit", env...)
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, errors.Join(errs...))
+	assert.True(t, passed[0], outs[0])
+	assert.True(t, passed[1], outs[1])
+	assert.Regexp(t, `acquired a slot for "engine" after \d+ms`, outs[0]+outs[1])
+}
+
+func TestMachineLimitStale(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t, testctx.WithMachineLimit[*testing.T]("stale", 1)).
+			Run("crash", func(ctx context.Context, t *testctx.T) {
+				// Exit while holding the slot
+				os.Exit(1)
+			})
+		return
+	}
+
+	dir := t.TempDir()
+	_, passed := runSubprocess(t, "TestMachineLimitStale", testctx.LockDirEnv+"="+dir)
+	assert.False(t, passed)
+	t.Setenv(testctx.LockDirEnv, dir)
+
+	tt := testctx.New(t,
+		testctx.WithTimeout[*testing.T](time.Second),
+		testctx.WithMachineLimit[*testing.T]("stale", 1),
+	)
+	var ran bool
+	tt.Run("after crash", func(ctx context.Context, t *testctx.T) {
+		ran = true
+	})
+	assert.True(t, ran, "slots held by dead processes should be reclaimed")
+}
+
+func TestMachineLimitCanceled(t *testing.T) {
+	if inSubprocess() {
+		testctx.LockWaitWarning = 20 * time.Millisecond
+		tt := testctx.New(t)
+		// The holder's only slot stays taken while it pauses, so the waiter
+		// times out with the holder's pid in its diagnostics
+		tt.Using(testctx.WithMachineLimit[*testing.T]("canceled", 1)).Run("holder", func(ctx context.Context, t *testctx.T) {
+			t.Unwrap().Parallel()
+			time.Sleep(testctx.ScaleTimeout(300 * time.Millisecond))
+		})
+		tt.Using(
+			testctx.WithParallel(),
+			testctx.WithTimeout[*testing.T](100*time.Millisecond),
+			testctx.WithMachineLimit[*testing.T]("canceled", 1),
+		).Run("waiter", func(ctx context.Context, t *testctx.T) {})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestMachineLimitCanceled", testctx.LockDirEnv+"="+t.TempDir())
+	assert.False(t, passed)
+	assert.Contains(t, out, "--- PASS: TestMachineLimitCanceled/holder")
+	assert.Contains(t, out, "--- FAIL: TestMachineLimitCanceled/waiter")
+	assert.Regexp(t, `held by: pid \d+: TestMachineLimitCanceled/holder`, out)
+	assert.Contains(t, out, "context deadline exceeded")
+}
+
+func TestLockDirPrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions don't apply on Windows")
+	}
+
+	t.Setenv(testctx.LockDirEnv, "")
+	assert.Equal(t, "testctx-locks-"+strconv.Itoa(os.Getuid()), filepath.Base(testctx.LockDir()))
+
+	dir := filepath.Join(t.TempDir(), "locks")
+	t.Setenv(testctx.LockDirEnv, dir)
+	tt := testctx.New(t, testctx.WithMachineLimit[*testing.T]("private", 1))
+	tt.Run("locked", func(ctx context.Context, t *testctx.T) {
+		info, err := os.Stat(dir)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+		info, err = os.Stat(filepath.Join(dir, "private.0.lock"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+}
@@ -2,14 +2,18 @@ package testctx
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 )
 
-// WithTimeout creates middleware that adds a timeout to the test context
+// WithTimeout creates middleware that adds a timeout to the test context.
+// The timeout is subject to the timeout policy; see ScaleTimeout and
+// TimeoutOverrider.
 func WithTimeout[T Runner[T]](d time.Duration) Middleware[T] {
 	return func(next RunFunc[T]) RunFunc[T] {
 		return func(ctx context.Context, t *W[T]) {
+			ctx, d := effectiveTimeout(ctx, t, d)
 			ctx, cancel := context.WithTimeout(ctx, d)
 			t.Cleanup(cancel)
 			next(ctx, t)
@@ -17,11 +21,41 @@ func WithTimeout[T Runner[T]](d time.Duration) Middleware[T] {
 	}
 }
 
-// WithParallel creates middleware that runs tests in parallel
+// WithParallel creates middleware that runs tests in parallel. Running it
+// more than once for the same test, e.g. for each attempt of WithRetry, has
+// no further effect.
+//
+// A parallel test pauses until its parent's body returns, so place
+// WithParallel before middleware that hold a resource for the duration of
+// the test, such as WithConcurrencyLimit, WithMachineLimit, WithExclusive
+// and WithShared. Otherwise a paused test would hold on to the resource, and
+// a serial sibling waiting for it would keep the parent's body from ever
+// returning.
 func WithParallel() Middleware[*testing.T] {
 	return func(next TestFunc) TestFunc {
 		return func(ctx context.Context, t *W[*testing.T]) {
-			t.Unwrap().Parallel()
+			if t.state.parallel.CompareAndSwap(false, true) {
+				t.Unwrap().Parallel()
+			}
+			next(ctx, t)
+		}
+	}
+}
+
+// WithCancelOnFailure creates middleware that cancels the test context as
+// soon as the test reports a failure via Error, Errorf, Fatal or Fatalf, so
+// that background work watching the context can unwind right away. The
+// cancellation cause is "test failed: <message>", which wraps ErrFailed and
+// is available via context.Cause. Failures of subtests, or those reported
+// directly on the underlying test (e.g. Fail), do not cancel the context.
+func WithCancelOnFailure[T Runner[T]]() Middleware[T] {
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, t *W[T]) {
+			ctx, cancel := context.WithCancelCause(ctx)
+			t.Cleanup(func() { cancel(nil) })
+			t.state.onFailure(func(msg string) {
+				cancel(fmt.Errorf("%w: %s", ErrFailed, msg))
+			})
 			next(ctx, t)
 		}
 	}
@@ -0,0 +1,86 @@
+package testctx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelOnFailure(t *testing.T) {
+	if inSubprocess() {
+		tt := testctx.New(t, testctx.WithCancelOnFailure[*testing.T]())
+		tt.Run("failing", func(ctx context.Context, t *testctx.T) {
+			t.Redact("hunter2")
+
+			t.Run("passing-child", func(ctx context.Context, t *testctx.T) {
+				t.Log("child passed")
+			})
+			t.Run("failing-child", func(ctx context.Context, t *testctx.T) {
+				t.Error("child failed")
+			})
+			if ctx.Err() != nil {
+				t.Log("canceled by child failure")
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				<-ctx.Done()
+				t.Logf("background work stopped: %v", context.Cause(ctx))
+			}()
+			t.Errorf("bad password: %s", "hunter2")
+			<-done
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestCancelOnFailure")
+	assert.False(t, passed)
+	assert.Contains(t, out, "background work stopped: test failed: bad password: ***")
+	assert.NotContains(t, out, "canceled by child failure")
+}
+
+func TestParallelBeforeLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		parallel, serial testctx.Middleware[*testing.T]
+	}{
+		{
+			name:     "concurrency limit",
+			parallel: testctx.WithConcurrencyLimit[*testing.T]("parallel-first", 1),
+			serial:   testctx.WithConcurrencyLimit[*testing.T]("parallel-first", 1),
+		},
+		{
+			name:     "exclusive",
+			parallel: testctx.WithExclusive[*testing.T]("parallel-first"),
+			serial:   testctx.WithShared[*testing.T]("parallel-first"),
+		},
+		{
+			name:     "machine limit",
+			parallel: testctx.WithMachineLimit[*testing.T]("parallel-first", 1),
+			serial:   testctx.WithMachineLimit[*testing.T]("parallel-first", 1),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(testctx.LockDirEnv, t.TempDir())
+
+			var order []string
+			t.Cleanup(func() {
+				assert.Equal(t, []string{"serial", "parallel"}, order)
+			})
+
+			// The parallel test doesn't hold the resource while paused, so
+			// the serial one can take it
+			tt := testctx.New(t, testctx.WithTimeout[*testing.T](time.Minute))
+			tt.Using(testctx.WithParallel(), tc.parallel).Run("parallel", func(ctx context.Context, t *testctx.T) {
+				order = append(order, "parallel")
+			})
+			tt.Using(tc.serial).Run("serial", func(ctx context.Context, t *testctx.T) {
+				order = append(order, "serial")
+			})
+		})
+	}
+}
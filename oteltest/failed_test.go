@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"testing"
 	"time"
 
@@ -90,6 +91,25 @@ func TestSubprocess(t *testing.T) {
 		require.NoError(t, fmt.Errorf("something failed"))
 	})
 
+	tt.Using(testctx.WithPanicRecovery[*testing.T]()).Run("Panic", func(ctx context.Context, t *testctx.T) {
+		panic("boom")
+	})
+
+	tt.Run("FatalInGoroutine", func(ctx context.Context, t *testctx.T) {
+		go t.Fatal("fatal in goroutine")
+		<-ctx.Done()
+	})
+
+	testctx.New(t,
+		testctx.WithCancelOnFailure[*testing.T](),
+		oteltest.WithTracing(oteltest.TraceConfig[*testing.T]{
+			TracerProvider: tracerProvider,
+		}),
+	).Run("CancelOnFailure", func(ctx context.Context, t *testctx.T) {
+		t.Error("canceling error")
+		<-ctx.Done()
+	})
+
 	tt.Run("ParallelChildFails", func(ctx context.Context, t *testctx.T) {
 		t.Run("passing", func(ctx context.Context, t *testctx.T) {
 			t.Unwrap().Parallel()
@@ -170,6 +190,26 @@ func TestFailedTestErrorMessages(t *testing.T) {
 			subtest:  "TestifyRequireNoError",
 			wantDesc: "Received unexpected error:\nsomething failed",
 		},
+		{
+			name:    "recovered panic is a failure",
+			subtest: "Panic",
+			check: func(t *testing.T, spans []spanResult) {
+				require.Len(t, spans, 1)
+				assert.Equal(t, int(codes.Error), spans[0].StatusCode)
+				assert.True(t, strings.HasPrefix(spans[0].StatusDesc, "test panicked: boom\n"),
+					"span status should carry the panic: %q", spans[0].StatusDesc)
+			},
+		},
+		{
+			name:     "Fatal in a goroutine is a failure",
+			subtest:  "FatalInGoroutine",
+			wantDesc: "fatal in goroutine",
+		},
+		{
+			name:     "failure canceling the context is a failure",
+			subtest:  "CancelOnFailure",
+			wantDesc: "canceling error",
+		},
 		{
 			name:    "parallel child failure reflects on parent",
 			subtest: "ParallelChildFails",
@@ -4,7 +4,7 @@ go 1.25.0
 
 require (
 	github.com/dagger/otel-go v1.41.1-0.20260303185236-072f65948887
-	github.com/dagger/testctx v0.1.2
+	github.com/dagger/testctx v0.2.0
 	github.com/stretchr/testify v1.11.1
 	go.opentelemetry.io/otel v1.41.0
 	go.opentelemetry.io/otel/log v0.17.0
@@ -43,3 +43,7 @@ require (
 	google.golang.org/protobuf v1.36.11 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+// oteltest is developed alongside the testctx API it requires; build against
+// the local copy until v0.2.0 is tagged
+replace github.com/dagger/testctx => ../
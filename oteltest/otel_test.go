@@ -3,6 +3,7 @@ package oteltest_test
 import (
 	"context"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -155,6 +156,79 @@ func (OTelSuite) TestStartOptions(ctx context.Context, t *testctx.T) {
 	assert.Contains(t, passSpan.Attributes(), attribute.String("test.suite", "otel_test"))
 }
 
+func (OTelSuite) TestTestAttrs(ctx context.Context, t *testctx.T) {
+	spanRecorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+
+	tt := testctx.New(t.Unwrap(), oteltest.WithTracing(oteltest.TraceConfig[*testing.T]{
+		TracerProvider: tracerProvider,
+	}))
+
+	tt.Run("with-attr", func(ctx context.Context, t *testctx.T) {
+		t.Attr("issue", "1234")
+	})
+
+	spans := spanRecorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("issue", "1234"))
+}
+
+func (OTelSuite) TestSoftTimeoutEvents(ctx context.Context, t *testctx.T) {
+	spanRecorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+
+	tt := testctx.New(t.Unwrap(),
+		oteltest.WithTracing(oteltest.TraceConfig[*testing.T]{
+			TracerProvider: tracerProvider,
+		}),
+		testctx.WithSoftTimeout[*testing.T](50*time.Millisecond),
+	)
+
+	// The soft timeout is subject to the timeout policy
+	softTimeout := testctx.ScaleTimeout(50 * time.Millisecond)
+	tt.Run("slow-test", func(ctx context.Context, t *testctx.T) {
+		time.Sleep(softTimeout * 3 / 2)
+	})
+
+	spans := spanRecorder.Ended()
+	require.Len(t, spans, 1)
+	events := spans[0].Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, testctx.SoftTimeoutEvent, events[0].Name)
+	assert.Contains(t, events[0].Attributes, attribute.String("soft_timeout", softTimeout.String()))
+}
+
+func (OTelSuite) TestRetryAttempts(ctx context.Context, t *testctx.T) {
+	spanRecorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+
+	tt := testctx.New(t.Unwrap(),
+		testctx.WithRetry[*testing.T](2),
+		oteltest.WithTracing(oteltest.TraceConfig[*testing.T]{
+			TracerProvider: tracerProvider,
+		}),
+	)
+
+	tt.Run("flaky-test", func(ctx context.Context, t *testctx.T) {
+		t.Attr("tried", strconv.Itoa(testctx.Attempt(ctx)))
+		if testctx.Attempt(ctx) == 1 {
+			t.Error("first try")
+		}
+	})
+
+	// Each attempt gets its own span
+	spans := spanRecorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("test.attempt", 1))
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[1].Attributes(), attribute.Int("test.attempt", 2))
+	assert.Equal(t, codes.Ok, spans[1].Status().Code)
+
+	// Attributes set by an attempt don't carry over to the next one
+	assert.Contains(t, spans[1].Attributes(), attribute.String("tried", "2"))
+	assert.NotContains(t, spans[1].Attributes(), attribute.String("tried", "1"))
+}
+
 func BenchmarkWithTracing(b *testing.B) {
 	spanRecorder := tracetest.NewSpanRecorder()
 	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
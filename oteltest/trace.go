@@ -58,6 +58,9 @@ func WithTracing[T testctx.Runner[T]](cfg ...TraceConfig[T]) testctx.Middleware[
 			if testPackage != "" {
 				attrs = append(attrs, semconv.TestSuiteName(testPackage))
 			}
+			if attempt := testctx.Attempt(ctx); attempt > 0 {
+				attrs = append(attrs, attribute.Int("test.attempt", attempt))
+			}
 			opts := []trace.SpanStartOption{
 				trace.WithAttributes(attrs...),
 				trace.WithAttributes(c.Attributes...),
@@ -86,13 +89,16 @@ func WithTracing[T testctx.Runner[T]](cfg ...TraceConfig[T]) testctx.Middleware[
 			// Snapshot the sync-phase end time and context error in a
 			// defer. This fires when the test function returns — before
 			// Go waits for parallel subtests and before WithTimeout's
-			// defer cancel() unwinds. Capturing ctx.Err() here avoids
-			// confusing WithTimeout's cancel with a real interruption.
+			// defer cancel() unwinds. Capturing the error here avoids
+			// confusing WithTimeout's cancel with a real interruption. The
+			// cause (e.g. testctx.ErrInterrupted) makes for a better status.
 			var syncEnd time.Time
 			var ctxErr error
 			defer func() {
 				syncEnd = time.Now()
-				ctxErr = ctx.Err()
+				if ctx.Err() != nil {
+					ctxErr = context.Cause(ctx)
+				}
 			}()
 
 			// Use Cleanup to set the final status after all subtests
@@ -101,7 +107,9 @@ func WithTracing[T testctx.Runner[T]](cfg ...TraceConfig[T]) testctx.Middleware[
 			// own work, not time spent waiting for parallel children.
 			w.Cleanup(func() {
 				var testStatus attribute.KeyValue
-				if ctxErr != nil {
+				// Failures and panics recovered by testctx.WithPanicRecovery
+				// may cancel the context too, but aren't interruptions.
+				if ctxErr != nil && !errors.Is(ctxErr, testctx.ErrFailed) && !errors.Is(ctxErr, testctx.ErrPanicked) {
 					// Test was interrupted (timeout or cancellation)
 					span.SetStatus(codes.Error, "test interrupted: "+ctxErr.Error())
 					if errors.Is(ctxErr, context.DeadlineExceeded) {
@@ -124,17 +132,32 @@ func WithTracing[T testctx.Runner[T]](cfg ...TraceConfig[T]) testctx.Middleware[
 					testStatus = semconv.TestCaseResultStatusPass
 				}
 				span.SetAttributes(testStatus)
+				span.SetAttributes(keyValues(w.Attrs())...)
 				span.End(trace.WithTimestamp(syncEnd))
 			})
 
 			// Store the span in the context so that it can be linked to in subtests
 			ctx = context.WithValue(ctx, testSpanKey{}, span)
 
+			// Record events emitted by other middleware (e.g. soft timeouts) on the span
+			ctx = testctx.ContextWithEventHandler(ctx, func(_ context.Context, name string, attrs ...testctx.Attr) {
+				span.AddEvent(name, trace.WithAttributes(keyValues(attrs)...))
+			})
+
 			next(ctx, w.WithLogger(errorsAcc))
 		}
 	}
 }
 
+// keyValues converts test attributes to span attributes
+func keyValues(attrs []testctx.Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, attribute.String(attr.Key, attr.Value))
+	}
+	return kvs
+}
+
 // errorAccumulator is a Logger that captures Error/Errorf messages so they
 // can be attached to a span status when the test fails.
 type errorAccumulator struct {
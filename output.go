@@ -0,0 +1,97 @@
+//go:build go1.25
+
+package testctx
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Attr calls through to the underlying test/benchmark type and records the
+// attribute so that middleware can retrieve it via Attrs
+func (w *W[T]) Attr(key, value string) {
+	if w.isDetached() {
+		return
+	}
+	w.tb.Attr(key, value)
+	w.recordAttr(key, value)
+}
+
+// Output returns a writer that writes to the underlying test/benchmark output.
+// Written data is split into lines, which are redacted (see Redact) and also
+// sent to any loggers added via WithLogger. Like testing.T.Output, it
+// returns the same writer for every call during a test, and a trailing
+// partial line is flushed when the test finishes.
+func (w *W[T]) Output() io.Writer {
+	var created *outputWriter
+	w.state.mu.Lock()
+	if w.state.output == nil {
+		created = w.newOutputWriter()
+		w.state.output = created
+	}
+	out := w.state.output
+	w.state.mu.Unlock()
+
+	if created != nil && !w.isDetached() {
+		// The writer is shared by every attempt of a WithRetry test, so
+		// flush it when the test itself finishes
+		w.tb.Cleanup(created.flush)
+	}
+	return out
+}
+
+// newOutputWriter returns the writer for Output, which writes via w
+func (w *W[T]) newOutputWriter() *outputWriter {
+	return &outputWriter{
+		write: func(line string) {
+			if w.isDetached() {
+				return
+			}
+			io.WriteString(w.tb.Output(), line)
+			if w.loggers != nil {
+				w.loggers.Log(strings.TrimSuffix(line, "\n"))
+			}
+		},
+		redact: func(line string) string {
+			if r := w.redactor(); r != nil {
+				return r.Replace(line)
+			}
+			return line
+		},
+	}
+}
+
+// outputWriter buffers writes until a full line is available
+type outputWriter struct {
+	write  func(line string)
+	redact func(line string) string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, p...)
+	for {
+		i := bytes.IndexByte(o.buf, '\n')
+		if i < 0 {
+			break
+		}
+		o.write(o.redact(string(o.buf[:i+1])))
+		o.buf = o.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (o *outputWriter) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.buf) > 0 {
+		o.write(o.redact(string(o.buf)) + "\n")
+		o.buf = nil
+	}
+}
@@ -0,0 +1,58 @@
+//go:build go1.25
+
+package testctx_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutput(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+
+	tt := testctx.New(t).WithLogger(rec)
+	tt.Run("subtest", func(ctx context.Context, t *testctx.T) {
+		t.Redact("hunter2")
+		out := t.Output()
+		fmt.Fprint(out, "first ")
+		fmt.Fprintln(out, "line")
+		// Every call returns the same writer, so lines can be written in
+		// pieces
+		fmt.Fprint(t.Output(), "second ")
+		fmt.Fprintln(t.Output(), "line")
+		fmt.Fprint(out, "password: hunter2\ntrailing")
+	})
+
+	assert.Equal(t, []string{
+		"first line",
+		"second line",
+		"password: ***",
+		"trailing",
+	}, rec.Messages())
+}
+
+func TestAttr(t *testing.T) {
+	var attrs []testctx.Attr
+
+	tt := testctx.New(t).Using(func(next testctx.TestFunc) testctx.TestFunc {
+		return func(ctx context.Context, t *testctx.T) {
+			t.Cleanup(func() {
+				attrs = t.Attrs()
+			})
+			next(ctx, t)
+		}
+	})
+
+	tt.Run("subtest", func(ctx context.Context, t *testctx.T) {
+		t.Attr("issue", "1234")
+		t.Run("child", func(ctx context.Context, t *testctx.T) {
+			t.Attr("child", "only")
+		})
+	})
+
+	assert.Equal(t, []testctx.Attr{{Key: "issue", Value: "1234"}}, attrs)
+}
@@ -0,0 +1,64 @@
+package testctx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicEvent is the name of the event emitted when WithPanicRecovery
+// recovers a panic
+const PanicEvent = "testctx.panic"
+
+// ErrPanicked is the cause of test context cancellation when
+// WithPanicRecovery recovers a panic
+var ErrPanicked = errors.New("test panicked")
+
+// WithPanicRecovery creates middleware that recovers panics in the test body
+// and reports them as test failures, instead of letting them abort the whole
+// test binary along with every other test still running. The panic value and
+// the stack of the panicking goroutine are reported via Error (and therefore
+// any loggers) and emitted as a PanicEvent, and the test context is canceled
+// with an ErrPanicked cause.
+//
+// Only panics on the test goroutine are recovered; use W.Go for goroutines
+// that should be covered too. Place it after middleware that observe the
+// test's outcome, such as tracing, so that they see the panic as an ordinary
+// failure.
+func WithPanicRecovery[T Runner[T]]() Middleware[T] {
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				if _, ok := r.(abortAttempt); ok {
+					// A fatal failure during an attempt of WithRetry
+					panic(r)
+				}
+				value, stack := r, debug.Stack()
+				// Keep the original stack of panics re-raised from another
+				// goroutine, e.g. by WithHardTimeout
+				if p, ok := r.(*goroutinePanic); ok {
+					value, stack = p.value, p.stack
+				}
+
+				// Errorf redacts its message, but the event bypasses loggers
+				msg, trace := fmt.Sprint(value), string(stack)
+				if r := w.redactor(); r != nil {
+					msg, trace = r.Replace(msg), r.Replace(trace)
+				}
+
+				w.Errorf("test panicked: %s\n\n%s", msg, trace)
+				Event(ctx, PanicEvent,
+					Attr{Key: "value", Value: msg},
+					Attr{Key: "stack", Value: trace},
+				)
+				w.cancel(fmt.Errorf("%w: %s", ErrPanicked, msg))
+			}()
+			next(ctx, w)
+		}
+	}
+}
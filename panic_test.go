@@ -0,0 +1,69 @@
+package testctx_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanicRecovery(t *testing.T) {
+	if inSubprocess() {
+		rec := testctx.NewLogRecorder()
+		var events, values []string
+		var mu sync.Mutex
+		ctx := testctx.ContextWithEventHandler(context.Background(), func(_ context.Context, name string, attrs ...testctx.Attr) {
+			// The subtests run in parallel
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, name)
+			for _, attr := range attrs {
+				if attr.Key == "value" {
+					values = append(values, attr.Value)
+				}
+			}
+		})
+		tt := testctx.New(t,
+			testctx.WithParallel(),
+			testctx.WithLogRecorder[*testing.T](rec),
+			testctx.WithPanicRecovery[*testing.T]()).WithContext(ctx)
+
+		tt.Cleanup(func() {
+			t.Logf("recorded panic: %v", rec.Contains("test panicked: boom"))
+			t.Logf("events: %v", events)
+			slices.Sort(values)
+			t.Logf("values: %q", values)
+		})
+
+		tt.Run("panics", func(ctx context.Context, t *testctx.T) {
+			t.Cleanup(func() {
+				t.Logf("cleanup ran, context canceled: %v", context.Cause(ctx))
+			})
+			panic("boom")
+		})
+
+		tt.Run("passes", func(ctx context.Context, t *testctx.T) {})
+
+		tt.Run("leaks", func(ctx context.Context, t *testctx.T) {
+			t.Redact("hunter2")
+			panic("password is hunter2")
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestPanicRecovery")
+	assert.False(t, passed)
+	assert.Contains(t, out, "--- FAIL: TestPanicRecovery/panics")
+	assert.Contains(t, out, "--- PASS: TestPanicRecovery/passes")
+	assert.Regexp(t, `test panicked: boom\s+goroutine \d+`, out)
+	assert.Regexp(t, `panic_test.go:\d+`, out)
+	assert.Contains(t, out, "cleanup ran, context canceled: test panicked: boom")
+	assert.Contains(t, out, "recorded panic: true")
+	assert.Contains(t, out, "events: ["+testctx.PanicEvent+" "+testctx.PanicEvent+"]")
+	assert.Contains(t, out, `values: ["boom" "password is ***"]`)
+	assert.NotContains(t, out, "hunter2")
+	assert.NotContains(t, out, "panic: boom")
+}
@@ -0,0 +1,80 @@
+package testctx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+)
+
+// maxPortAttempts bounds how many free ports Port tries to reserve before
+// giving up
+const maxPortAttempts = 100
+
+// Port reserves a free TCP port on the loopback interface for the rest of
+// the test, failing the test if none can be found before ctx is done. The
+// reservation is recorded in a lock file under LockDir, so that tests in
+// this and other test binaries reserving ports the same way never get the
+// same one, and it is released, removing the lock file, when the test
+// finishes. The port is logged to help debugging.
+//
+// The port is free when reserved, but nothing stops processes that don't
+// reserve ports via Port from binding it in the meantime; start listening
+// on it soon.
+func (w *W[T]) Port(ctx context.Context) int {
+	return w.reservePort(ctx, "tcp")
+}
+
+// UDPPort is like Port, but reserves a UDP port.
+func (w *W[T]) UDPPort(ctx context.Context) int {
+	return w.reservePort(ctx, "udp")
+}
+
+func (w *W[T]) reservePort(ctx context.Context, network string) int {
+	dir, err := makeLockDir("ports")
+	if err != nil {
+		w.Fatalf("failed to reserve %s port: %v", network, err)
+	}
+	for range maxPortAttempts {
+		if err := context.Cause(ctx); err != nil {
+			w.Fatalf("failed to reserve %s port: %v", network, err)
+		}
+		port, err := freePort(network)
+		if err != nil {
+			w.Fatalf("failed to reserve %s port: %v", network, err)
+		}
+		lf, err := tryLockFile(filepath.Join(dir, fmt.Sprintf("%s-%d.lock", network, port)), w.Name())
+		if err != nil {
+			w.Fatalf("failed to reserve %s port: %v", network, err)
+		}
+		if lf == nil {
+			// Reserved by another test
+			continue
+		}
+		w.Cleanup(lf.release)
+		w.Logf("reserved %s port %d", network, port)
+		return port
+	}
+	w.Fatalf("failed to reserve %s port: no free port found after %d attempts", network, maxPortAttempts)
+	return 0
+}
+
+// freePort asks the kernel for a free port on the loopback interface
+func freePort(network string) (int, error) {
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port, nil
+	default:
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return 0, err
+		}
+		defer l.Close()
+		return l.Addr().(*net.TCPAddr).Port, nil
+	}
+}
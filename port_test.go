@@ -0,0 +1,65 @@
+package testctx_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPort(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(testctx.LockDirEnv, dir)
+
+	rec := testctx.NewLogRecorder()
+	tt := testctx.New(t, testctx.WithLogRecorder[*testing.T](rec))
+
+	tt.Run("reserve", func(ctx context.Context, t *testctx.T) {
+		ports := map[int]bool{}
+		for range 10 {
+			port := t.Port(ctx)
+			assert.False(t, ports[port], "port %d reserved twice", port)
+			ports[port] = true
+
+			l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+			require.NoError(t, err)
+			l.Close()
+		}
+
+		port := t.UDPPort(ctx)
+		conn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		require.NoError(t, err)
+		conn.Close()
+	})
+
+	assert.Len(t, rec.Match(`^reserved tcp port \d+$`), 10)
+	assert.Len(t, rec.Match(`^reserved udp port \d+$`), 1)
+
+	// Lock files are removed once the ports are released
+	entries, err := os.ReadDir(filepath.Join(dir, "ports"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPortCanceled(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t).Run("canceled", func(ctx context.Context, t *testctx.T) {
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+			t.Port(ctx)
+			t.Log("unreachable")
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestPortCanceled")
+	assert.False(t, passed)
+	assert.Contains(t, out, "failed to reserve tcp port: context canceled")
+	assert.NotContains(t, out, "unreachable")
+}
@@ -0,0 +1,204 @@
+package testctx
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultQuarantineFile is the quarantine list read by WithQuarantine unless
+// configured otherwise. Like other test data, it is relative to the package
+// directory.
+const DefaultQuarantineFile = "testctx-quarantine.txt"
+
+// QuarantineFailureEvent is the name of the event emitted for each failure
+// of a quarantined test
+const QuarantineFailureEvent = "testctx.quarantine_failure"
+
+// QuarantineConfig holds configuration for the WithQuarantine middleware
+type QuarantineConfig struct {
+	// File is the quarantine list. Defaults to DefaultQuarantineFile; a
+	// missing file quarantines nothing.
+	File string
+}
+
+// WithQuarantine creates middleware that keeps known-flaky tests listed in
+// a quarantine file from failing the build. The file lists one pattern per
+// line, matched against full test names as reported by go test (e.g.
+// "TestSuite/TestFlaky" or "TestSuite/TestFlaky/*"); the elements of a
+// pattern are matched as by path.Match, so "*" doesn't match across
+// subtests. Blank lines and lines starting with "#" are ignored.
+//
+// Quarantined tests, and their subtests, still run. Their failures are
+// logged instead of failing the test, and emitted as a
+// QuarantineFailureEvent. Fatal failures skip the rest of the test, or,
+// on goroutines other than the test goroutine, exit the goroutine without
+// canceling the test context. Tests are tagged with a
+// "testctx.quarantined" attribute (see W.Attrs), so that tracing can tell
+// them apart.
+//
+// Since middleware is applied at every level, the outermost application
+// (e.g. around the RunTests loop) reads the file and, once its tests are
+// done, logs which quarantined tests passed and can be taken off the list.
+func WithQuarantine[T Runner[T]](cfg ...QuarantineConfig) Middleware[T] {
+	var c QuarantineConfig
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	if c.File == "" {
+		c.File = DefaultQuarantineFile
+	}
+
+	key := &scopeKey{}
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			s, ok := ctx.Value(key).(*quarantineSuite)
+			if !ok {
+				patterns, err := readQuarantineFile(c.File)
+				if err != nil {
+					w.Errorf("failed to read quarantine list: %v", err)
+				}
+				s = &quarantineSuite{file: c.File, patterns: patterns}
+				w.Cleanup(s.summarize(w.Logf))
+				ctx = context.WithValue(ctx, key, s)
+			}
+
+			var q *quarantine
+			switch {
+			case w.quarantine != nil:
+				// Subtest of a quarantined test
+				q = &quarantine{parent: w.quarantine}
+			case s.matches(w.Name()):
+				q = &quarantine{}
+				name := w.Name()
+				w.Cleanup(func() {
+					if !w.Skipped() || q.hasFailed() {
+						s.finish(name, q.hasFailed())
+					}
+				})
+			default:
+				next(ctx, w)
+				return
+			}
+
+			w.recordAttr("testctx.quarantined", "true")
+			qw := w.WithContext(ctx)
+			qw.quarantine = q
+			next(ctx, qw)
+		}
+	}
+}
+
+// readQuarantineFile reads the patterns listed in a quarantine file
+func readQuarantineFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return patterns, fmt.Errorf("%s:%d: invalid pattern %q: %w", file, line, pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, scanner.Err()
+}
+
+// quarantineSuite tracks the outcome of the quarantined tests in the
+// subtree of tests WithQuarantine is first applied to
+type quarantineSuite struct {
+	file     string
+	patterns []string
+
+	mu     sync.Mutex
+	passed []string
+	failed []string
+}
+
+// matches reports whether the named test is on the quarantine list
+func (s *quarantineSuite) matches(name string) bool {
+	for _, pattern := range s.patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// finish records the outcome of a quarantined test
+func (s *quarantineSuite) finish(name string, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if failed {
+		s.failed = append(s.failed, name)
+	} else {
+		s.passed = append(s.passed, name)
+	}
+}
+
+// summarize returns a cleanup function that logs the outcome of the
+// quarantined tests via logf
+func (s *quarantineSuite) summarize(logf func(format string, args ...any)) func() {
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.passed)+len(s.failed) == 0 {
+			return
+		}
+		slices.Sort(s.passed)
+		summary := fmt.Sprintf("%d quarantined test(s) failed", len(s.failed))
+		if len(s.passed) > 0 {
+			summary += fmt.Sprintf("; %d passed and can be removed from %s:\n%s",
+				len(s.passed), s.file, strings.Join(s.passed, "\n"))
+		}
+		logf("%s", summary)
+	}
+}
+
+// recordQuarantined logs a failure of a quarantined test
+func (w *W[T]) recordQuarantined(msg string) {
+	w.quarantine.fail()
+	w.Logf("quarantined test failed: %s", msg)
+	Event(w.ctx, QuarantineFailureEvent, Attr{Key: "message", Value: msg})
+}
+
+// quarantine records whether a quarantined test, or any of its subtests,
+// failed
+type quarantine struct {
+	parent *quarantine
+
+	mu     sync.Mutex
+	failed bool
+}
+
+func (q *quarantine) fail() {
+	for ; q != nil; q = q.parent {
+		q.mu.Lock()
+		q.failed = true
+		q.mu.Unlock()
+	}
+}
+
+func (q *quarantine) hasFailed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.failed
+}
@@ -0,0 +1,130 @@
+package testctx_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type quarantineSuite struct{}
+
+func (quarantineSuite) TestFlaky(ctx context.Context, t *testctx.T) {
+	t.Error("flaky failure")
+}
+
+func (quarantineSuite) TestFatal(ctx context.Context, t *testctx.T) {
+	require.Fail(t, "fatal failure")
+	t.Log("unreachable")
+}
+
+func (quarantineSuite) TestFixed(ctx context.Context, t *testctx.T) {}
+
+func (quarantineSuite) TestSubtests(ctx context.Context, t *testctx.T) {
+	t.Run("passes", func(ctx context.Context, t *testctx.T) {})
+	t.Run("fails", func(ctx context.Context, t *testctx.T) {
+		t.Error("subtest failure")
+	})
+}
+
+func (quarantineSuite) TestHealthy(ctx context.Context, t *testctx.T) {}
+
+func TestQuarantine(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quarantine.txt")
+	require.NoError(t, os.WriteFile(file, []byte(`# known flakes
+TestQuarantine/TestFlaky
+TestQuarantine/TestFatal
+TestQuarantine/TestFixed
+
+TestQuarantine/TestSubtests/*
+`), 0o644))
+
+	rec := testctx.NewLogRecorder()
+	// The summary is logged once the suite's root test finishes
+	t.Cleanup(func() {
+		assert.True(t, rec.Contains("3 quarantined test(s) failed; 2 passed and can be removed from "+file+":\n"+
+			"TestQuarantine/TestFixed\n"+
+			"TestQuarantine/TestSubtests/passes"))
+	})
+
+	var events []string
+	ctx := testctx.ContextWithEventHandler(context.Background(), func(_ context.Context, name string, attrs ...testctx.Attr) {
+		events = append(events, name)
+	})
+	tt := testctx.New(t,
+		testctx.WithLogRecorder[*testing.T](rec),
+		testctx.WithQuarantine[*testing.T](testctx.QuarantineConfig{File: file}),
+	).WithContext(ctx)
+
+	var quarantined []string
+	tt.Using(func(next testctx.RunFunc[*testing.T]) testctx.RunFunc[*testing.T] {
+		return func(ctx context.Context, w *testctx.W[*testing.T]) {
+			next(ctx, w)
+			if slices.Contains(w.Attrs(), testctx.Attr{Key: "testctx.quarantined", Value: "true"}) {
+				quarantined = append(quarantined, w.Name())
+			}
+		}
+	}).RunTests(quarantineSuite{})
+
+	assert.False(t, t.Failed())
+	assert.Zero(t, rec.Count(testctx.LogKindError))
+	assert.True(t, rec.Contains("quarantined test failed: flaky failure"))
+	assert.True(t, rec.Contains("fatal failure"))
+	assert.False(t, rec.Contains("unreachable"))
+	assert.True(t, rec.Contains("quarantined test failed: subtest failure"))
+	assert.Equal(t, []string{
+		testctx.QuarantineFailureEvent,
+		testctx.QuarantineFailureEvent,
+		testctx.QuarantineFailureEvent,
+	}, events)
+	assert.ElementsMatch(t, []string{
+		"TestQuarantine/TestFlaky",
+		"TestQuarantine/TestFixed",
+		"TestQuarantine/TestSubtests/passes",
+		"TestQuarantine/TestSubtests/fails",
+	}, quarantined)
+}
+
+func TestQuarantineMissingFile(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+	tt := testctx.New(t,
+		testctx.WithLogRecorder[*testing.T](rec),
+		testctx.WithQuarantine[*testing.T](testctx.QuarantineConfig{
+			File: filepath.Join(t.TempDir(), "missing.txt"),
+		}),
+	)
+
+	tt.Run("passes", func(ctx context.Context, t *testctx.T) {})
+	assert.Empty(t, rec.Entries())
+}
+
+func TestQuarantineGoroutineFatal(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quarantine.txt")
+	require.NoError(t, os.WriteFile(file, []byte("TestQuarantineGoroutineFatal/flaky\n"), 0o644))
+
+	rec := testctx.NewLogRecorder()
+	tt := testctx.New(t,
+		testctx.WithLogRecorder[*testing.T](rec),
+		testctx.WithQuarantine[*testing.T](testctx.QuarantineConfig{File: file}),
+	)
+
+	var ctxErr error
+	passed := tt.Run("flaky", func(ctx context.Context, t *testctx.T) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			t.Fatal("goroutine failure")
+		}()
+		<-done
+		ctxErr = context.Cause(ctx)
+	})
+
+	assert.True(t, passed)
+	assert.NoError(t, ctxErr, "the downgraded failure should not cancel the test")
+	assert.True(t, rec.Contains("quarantined test failed: goroutine failure"))
+}
@@ -0,0 +1,65 @@
+package testctx
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+)
+
+// RedactedMask is the text that replaces secrets in log messages
+const RedactedMask = "***"
+
+// secretsKey is the key used to store secrets in the context
+type secretsKey struct{}
+
+// ContextWithSecrets returns a context that registers the given secrets for
+// redaction. Any test whose context descends from the returned context masks
+// them in its log messages, just like secrets registered via W.Redact.
+func ContextWithSecrets(ctx context.Context, secrets ...string) context.Context {
+	existing, _ := ctx.Value(secretsKey{}).([]string)
+	return context.WithValue(ctx, secretsKey{}, append(slices.Clone(existing), secrets...))
+}
+
+// Redact registers secrets that should be masked in every message passing
+// through Log, Error, Fatal and Skip (and their formatted variants), both in
+// the test output and in loggers added via WithLogger. Registrations apply to
+// the current test and are inherited by its subtests.
+func (w *W[T]) Redact(secrets ...string) {
+	w.state.mu.Lock()
+	defer w.state.mu.Unlock()
+	for _, s := range secrets {
+		if s != "" {
+			w.state.secrets = append(w.state.secrets, s)
+		}
+	}
+}
+
+// redactor returns a replacer masking all secrets registered for this test,
+// its parents, and its context, or nil if there are none.
+func (w *W[T]) redactor() *strings.Replacer {
+	var secrets []string
+	if ctxSecrets, ok := w.ctx.Value(secretsKey{}).([]string); ok {
+		secrets = append(secrets, ctxSecrets...)
+	}
+	for s := w.state; s != nil; s = s.parent {
+		s.mu.Lock()
+		secrets = append(secrets, s.secrets...)
+		s.mu.Unlock()
+	}
+	secrets = slices.DeleteFunc(secrets, func(s string) bool { return s == "" })
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	// Replace longer secrets first so that a secret containing another
+	// secret is masked as a whole.
+	slices.SortFunc(secrets, func(a, b string) int {
+		return cmp.Or(len(b)-len(a), strings.Compare(a, b))
+	})
+	oldnew := make([]string, 0, len(secrets)*2)
+	for _, s := range slices.Compact(secrets) {
+		oldnew = append(oldnew, s, RedactedMask)
+	}
+	return strings.NewReplacer(oldnew...)
+}
@@ -0,0 +1,43 @@
+package testctx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	logs := testctx.NewLogRecorder()
+
+	tt := testctx.New(t).WithLogger(logs)
+	tt.Redact("hunter2")
+
+	tt.Run("subtest", func(ctx context.Context, t *testctx.T) {
+		t.Redact("s3cr3t")
+		t.Log("password is", "hunter2")
+		t.Logf("token is %s%s", "s3c", "r3t")
+	})
+
+	tt.Log("child secret s3cr3t is not registered here")
+
+	assert.Equal(t, []string{
+		"password is ***",
+		"token is ***",
+		"child secret s3cr3t is not registered here",
+	}, logs.Messages())
+}
+
+func TestRedactContext(t *testing.T) {
+	logs := testctx.NewLogRecorder()
+
+	tt := testctx.New(t).WithLogger(logs)
+	tt = tt.WithContext(testctx.ContextWithSecrets(tt.Context(), "abc", "abcdef"))
+
+	tt.Run("subtest", func(ctx context.Context, t *testctx.T) {
+		t.Log("abcdef abc")
+	})
+
+	assert.Equal(t, []string{"*** ***"}, logs.Messages())
+}
@@ -0,0 +1,313 @@
+package testctx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFDIgnores lists targets of file descriptors that the Go runtime
+// opens lazily and keeps open for the life of the process
+var DefaultFDIgnores = []string{
+	"anon_inode:[eventpoll]",
+	"anon_inode:[eventfd]",
+}
+
+// ResourceLeakConfig holds configuration for the WithResourceLeakCheck
+// middleware
+type ResourceLeakConfig struct {
+	// Fail makes leaks fail the test instead of merely logging them.
+	// Leaks are only ever logged when other tests ran concurrently, since
+	// they can't be attributed reliably.
+	Fail bool
+	// IgnoreFDs lists prefixes of file descriptor targets (as reported by
+	// readlink on /proc/self/fd/N) that are never reported. Defaults to
+	// DefaultFDIgnores.
+	IgnoreFDs []string
+	// Timeout is how long to wait for file descriptors to be closed and
+	// child processes to exit after the test finishes before reporting
+	// them. Defaults to one second, and is subject to the timeout policy;
+	// see ScaleTimeout.
+	Timeout time.Duration
+}
+
+// WithResourceLeakCheck creates middleware that reports file descriptors and
+// child processes that a test leaves behind. Open file descriptors and child
+// processes are recorded before the test runs, and any new ones still around
+// after the test and its cleanups have finished are reported along with
+// their targets and command lines.
+//
+// File descriptors and processes are per-process rather than per-goroutine,
+// so when other tests (besides the test's own subtests and parents) run at
+// the same time, leaks are attributed conservatively: they are logged as
+// possible leaks and never fail the test. Leaks already reported by a
+// subtest are not reported again by its parents.
+//
+// The check relies on /proc and does nothing on systems without it.
+func WithResourceLeakCheck[T Runner[T]](cfg ...ResourceLeakConfig) Middleware[T] {
+	var c ResourceLeakConfig
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	if c.IgnoreFDs == nil {
+		c.IgnoreFDs = DefaultFDIgnores
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = time.Second
+	}
+
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			before, err := snapshotResources()
+			if err != nil {
+				// No /proc; nothing to check
+				next(ctx, w)
+				return
+			}
+
+			check := resourceChecks.start(w.state)
+			// The snapshot is compared after the test's own cleanups, which
+			// are registered later and so run earlier, have closed their
+			// files and waited for their processes
+			w.Cleanup(func() {
+				defer resourceChecks.finish(check)
+
+				deadline := time.Now().Add(ScaleTimeout(c.Timeout))
+				for {
+					after, err := snapshotResources()
+					if err != nil {
+						w.Logf("failed to check for leaked resources: %v", err)
+						return
+					}
+					leaked := resourceChecks.leaked(before, after, c.IgnoreFDs)
+					if len(leaked) == 0 {
+						return
+					}
+					if time.Now().After(deadline) {
+						resourceChecks.report(leaked)
+						switch {
+						case check.concurrent():
+							w.Logf("possible resource leaks (other tests ran concurrently, so these may not belong to this test):\n%s",
+								strings.Join(leaked, "\n"))
+						case c.Fail:
+							w.Errorf("test leaked resources:\n%s", strings.Join(leaked, "\n"))
+						default:
+							w.Logf("test leaked resources:\n%s", strings.Join(leaked, "\n"))
+						}
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			})
+			next(ctx, w)
+		}
+	}
+}
+
+// resourceChecks tracks the resource leak checks in progress
+var resourceChecks = &resourceCheckRegistry{
+	active:   map[*resourceCheck]struct{}{},
+	reported: map[string]struct{}{},
+}
+
+type resourceCheckRegistry struct {
+	mu     sync.Mutex
+	active map[*resourceCheck]struct{}
+	// reported holds the leaks that have already been reported, so that
+	// parents don't report their subtests' leaks again
+	reported map[string]struct{}
+}
+
+// resourceCheck is a resource leak check for a single test
+type resourceCheck struct {
+	state *testState
+
+	mu      sync.Mutex
+	overlap bool
+}
+
+// concurrent reports whether unrelated tests ran during the check
+func (c *resourceCheck) concurrent() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.overlap
+}
+
+func (c *resourceCheck) markConcurrent() {
+	c.mu.Lock()
+	c.overlap = true
+	c.mu.Unlock()
+}
+
+// start begins a check for the given test, noting any overlap with checks
+// of unrelated tests
+func (r *resourceCheckRegistry) start(s *testState) *resourceCheck {
+	check := &resourceCheck{state: s}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for other := range r.active {
+		if !other.state.isAncestorOf(s) && !s.isAncestorOf(other.state) {
+			other.markConcurrent()
+			check.markConcurrent()
+		}
+	}
+	r.active[check] = struct{}{}
+	return check
+}
+
+func (r *resourceCheckRegistry) finish(check *resourceCheck) {
+	r.mu.Lock()
+	delete(r.active, check)
+	r.mu.Unlock()
+}
+
+// leaked describes the resources in after but not in before that haven't
+// been reported yet. Previously reported leaks that have since gone away are
+// forgotten, since their file descriptor numbers and PIDs may be reused.
+func (r *resourceCheckRegistry) leaked(before, after resources, ignoreFDs []string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := after.describe()
+	for desc := range r.reported {
+		if !slices.Contains(current, desc) {
+			delete(r.reported, desc)
+		}
+	}
+
+	var leaked []string
+	for fd, target := range after.fds {
+		if prev, ok := before.fds[fd]; ok && prev == target {
+			continue
+		}
+		if slices.ContainsFunc(ignoreFDs, func(prefix string) bool {
+			return strings.HasPrefix(target, prefix)
+		}) {
+			continue
+		}
+		if desc := describeFD(fd, target); !r.isReported(desc) {
+			leaked = append(leaked, desc)
+		}
+	}
+	for pid, cmdline := range after.children {
+		if prev, ok := before.children[pid]; ok && prev == cmdline {
+			continue
+		}
+		if desc := describeChild(pid, cmdline); !r.isReported(desc) {
+			leaked = append(leaked, desc)
+		}
+	}
+	slices.Sort(leaked)
+	return leaked
+}
+
+func (r *resourceCheckRegistry) isReported(desc string) bool {
+	_, ok := r.reported[desc]
+	return ok
+}
+
+func (r *resourceCheckRegistry) report(leaked []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, desc := range leaked {
+		r.reported[desc] = struct{}{}
+	}
+}
+
+// resources are the open file descriptors and child processes of the
+// current process
+type resources struct {
+	// fds maps file descriptors to their targets
+	fds map[int]string
+	// children maps child PIDs to their command lines
+	children map[int]string
+}
+
+func (r resources) describe() []string {
+	var descs []string
+	for fd, target := range r.fds {
+		descs = append(descs, describeFD(fd, target))
+	}
+	for pid, cmdline := range r.children {
+		descs = append(descs, describeChild(pid, cmdline))
+	}
+	return descs
+}
+
+func describeFD(fd int, target string) string {
+	return fmt.Sprintf("fd %d -> %s", fd, target)
+}
+
+func describeChild(pid int, cmdline string) string {
+	return fmt.Sprintf("pid %d: %s", pid, cmdline)
+}
+
+// snapshotResources records the open file descriptors and child processes
+// of the current process
+func snapshotResources() (resources, error) {
+	r := resources{
+		fds:      map[int]string{},
+		children: map[int]string{},
+	}
+
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return r, err
+	}
+	for _, e := range entries {
+		fd, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", e.Name()))
+		if err != nil || strings.HasPrefix(target, "/proc/") {
+			// Closed in the meantime, or used to take a snapshot
+			continue
+		}
+		r.fds[fd] = target
+	}
+
+	self := os.Getpid()
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	for _, stat := range stats {
+		content, err := os.ReadFile(stat)
+		if err != nil {
+			continue
+		}
+		// pid (comm) state ppid ...; comm may contain spaces and parens
+		end := bytes.LastIndexByte(content, ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(content[end+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		if ppid, _ := strconv.Atoi(fields[1]); ppid != self {
+			continue
+		}
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(stat)))
+		if err != nil {
+			continue
+		}
+		cmdline, _ := os.ReadFile(filepath.Join(filepath.Dir(stat), "cmdline"))
+		desc := strings.TrimSpace(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '})))
+		if desc == "" {
+			// Zombies have no command line
+			start := bytes.IndexByte(content, '(')
+			desc = "[" + string(content[start+1:end]) + "]"
+		}
+		if fields[0] == "Z" {
+			desc += " (zombie)"
+		}
+		r.children[pid] = desc
+	}
+	return r, nil
+}
@@ -0,0 +1,121 @@
+package testctx_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceLeakCheck(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("requires /proc")
+	}
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("requires sleep")
+	}
+
+	if inSubprocess() {
+		tt := testctx.New(t, testctx.WithResourceLeakCheck[*testing.T](testctx.ResourceLeakConfig{
+			Fail: true,
+		}))
+
+		var leakedFile *os.File
+		var leakedCmd *exec.Cmd
+		tt.Cleanup(func() {
+			if leakedFile != nil {
+				leakedFile.Close()
+			}
+			if leakedCmd != nil {
+				leakedCmd.Process.Kill()
+				leakedCmd.Wait()
+			}
+		})
+
+		tt.Run("leaks file", func(ctx context.Context, t *testctx.T) {
+			var err error
+			leakedFile, err = os.Create(filepath.Join(t.TempDir(), "leaked.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		tt.Run("leaks process", func(ctx context.Context, t *testctx.T) {
+			leakedCmd = exec.Command("sleep", "30")
+			if err := leakedCmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		tt.Run("cleans up", func(ctx context.Context, t *testctx.T) {
+			f, err := os.Create(filepath.Join(t.TempDir(), "closed.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { f.Close() })
+
+			cmd := exec.CommandContext(ctx, "sleep", "30")
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				cmd.Process.Kill()
+				cmd.Wait()
+			})
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestResourceLeakCheck")
+	assert.False(t, passed)
+	assert.Contains(t, out, "--- FAIL: TestResourceLeakCheck/leaks_file")
+	assert.Regexp(t, `fd \d+ -> \S+leaked.txt`, out)
+	assert.Contains(t, out, "--- FAIL: TestResourceLeakCheck/leaks_process")
+	assert.Regexp(t, `pid \d+: sleep 30`, out)
+	assert.Contains(t, out, "--- PASS: TestResourceLeakCheck/cleans_up")
+}
+
+func TestResourceLeakCheckParallel(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("requires /proc")
+	}
+
+	if inSubprocess() {
+		tt := testctx.New(t,
+			testctx.WithParallel(),
+			testctx.WithResourceLeakCheck[*testing.T](testctx.ResourceLeakConfig{
+				Fail: true,
+			}))
+
+		var leakedFile *os.File
+		tt.Cleanup(func() {
+			if leakedFile != nil {
+				leakedFile.Close()
+			}
+		})
+
+		leaked := make(chan struct{})
+		tt.Run("leaks file", func(ctx context.Context, t *testctx.T) {
+			defer close(leaked)
+			var err error
+			leakedFile, err = os.Create(filepath.Join(t.TempDir(), "leaked.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+		tt.Run("concurrent", func(ctx context.Context, t *testctx.T) {
+			// Overlap with the leaking test
+			<-leaked
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestResourceLeakCheckParallel")
+	assert.True(t, passed, "leaks alongside concurrent tests should not fail")
+	assert.Contains(t, out, "possible resource leaks")
+	assert.Regexp(t, `fd \d+ -> \S+leaked.txt`, out)
+}
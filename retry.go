@@ -0,0 +1,257 @@
+package testctx
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FlakyEvent is the name of the event emitted when a test run with
+// WithRetry passes only after failing at least once
+const FlakyEvent = "testctx.flaky"
+
+// attemptKey is the context key for the current attempt number
+type attemptKey struct{}
+
+// Attempt returns the number of the attempt the test is on, starting at 1,
+// when it runs with WithRetry, or 0 otherwise
+func Attempt(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
+
+// WithRetry creates middleware that runs a known-flaky test up to n times,
+// until it passes. Failures during every attempt but the last are recorded
+// instead of failing the test: they are logged, the attempt stops if they
+// are fatal, and the test is tried again. Failures during the last attempt
+// fail the test as usual. A test that passes only after a retry is reported
+// as flaky via a log message and a FlakyEvent.
+//
+// Each attempt gets its own context, whose attempt number is available via
+// Attempt, and runs the cleanup functions it registers before the next
+// attempt starts. Place it before tracing middleware to record each attempt
+// in its own span. It may go before or after WithParallel: only the first
+// attempt pauses the test.
+//
+// Subtests can't be retried without running them twice under the same
+// name, so once an attempt starts a subtest it becomes the last one: the
+// failures it recorded so far are reported, and so is anything that fails
+// afterwards.
+func WithRetry[T Runner[T]](n int) Middleware[T] {
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			var failures []string
+			for i := 1; ; i++ {
+				actx := context.WithValue(ctx, attemptKey{}, i)
+				if i >= n {
+					next(actx, w.WithContext(actx))
+					if i > 1 && !w.Failed() && !w.Skipped() {
+						reportFlaky(actx, w, i, n, failures)
+					}
+					return
+				}
+
+				mark := w.state.mark()
+				a := runAttempt(actx, w, next, i, n)
+				if a.isFinal() {
+					return
+				}
+				attemptFailures := a.failures()
+				if len(attemptFailures) == 0 {
+					if i > 1 && !w.Skipped() {
+						reportFlaky(actx, w, i, n, failures)
+					}
+					return
+				}
+				failures = append(failures, attemptFailures...)
+				w.state.rewind(mark)
+				w.Logf("attempt %d/%d failed; retrying", i, n)
+			}
+		}
+	}
+}
+
+// runAttempt runs a single attempt of a retried test, stopping early if it
+// fails fatally
+func runAttempt[T Runner[T]](ctx context.Context, w *W[T], next RunFunc[T], i, n int) (a *retryAttempt) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	a = &retryAttempt{number: i, of: n, cancel: cancel}
+	aw := w.WithContext(ctx)
+	aw.attempt = a
+
+	defer func() {
+		cancel(fmt.Errorf("attempt %d/%d finished", i, n))
+		a.runCleanups()
+		if r := recover(); r != nil {
+			if _, ok := r.(abortAttempt); !ok {
+				panic(r)
+			}
+		}
+	}()
+	next(ctx, aw)
+	return a
+}
+
+// reportFlaky reports a test that passed only after failing
+func reportFlaky[T Runner[T]](ctx context.Context, w *W[T], i, n int, failures []string) {
+	w.Logf("test is flaky: passed on attempt %d/%d after failing with:\n%s", i, n, strings.Join(failures, "\n"))
+	Event(ctx, FlakyEvent,
+		Attr{Key: "attempt", Value: strconv.Itoa(i)},
+		Attr{Key: "attempts", Value: strconv.Itoa(n)},
+		Attr{Key: "failures", Value: strings.Join(failures, "\n")},
+	)
+}
+
+// abortAttempt is panicked on the test goroutine to stop an attempt that
+// failed fatally; WithRetry recovers it
+type abortAttempt struct{}
+
+// retryAttempt records the failures of an attempt that may be retried
+type retryAttempt struct {
+	number, of int
+	cancel     context.CancelCauseFunc
+
+	// group holds the goroutines started via Go during the attempt; it is
+	// guarded by the test state's mutex, like the test's own group
+	group *goGroup
+
+	mu       sync.Mutex
+	failed   []string
+	cleanups []func()
+	// final is set once the attempt can no longer be retried, after which
+	// failures go through to the test as usual
+	final bool
+}
+
+// stateMark records how much per-test state a test has accumulated, so
+// that the state added by an attempt can be dropped before the next one
+type stateMark struct {
+	attrs, failureHooks int
+}
+
+func (s *testState) mark() stateMark {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return stateMark{attrs: len(s.attrs), failureHooks: len(s.failureHooks)}
+}
+
+func (s *testState) rewind(m stateMark) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = s.attrs[:m.attrs]
+	s.failureHooks = s.failureHooks[:m.failureHooks]
+}
+
+// recording returns the attempt whose failures are being recorded, if any
+func (w *W[T]) recording() *retryAttempt {
+	if w.attempt == nil || w.attempt.isFinal() {
+		return nil
+	}
+	return w.attempt
+}
+
+// recordFailure records a failure instead of failing the test if the test
+// is in an attempt that may be retried, or is quarantined, and reports
+// whether it did
+func (w *W[T]) recordFailure(msg string) bool {
+	a := w.recording()
+	if a == nil && w.quarantine == nil {
+		return false
+	}
+	if r := w.redactor(); r != nil {
+		msg = r.Replace(msg)
+	}
+	if a == nil {
+		w.recordQuarantined(msg)
+		return true
+	}
+	a.mu.Lock()
+	a.failed = append(a.failed, msg)
+	a.mu.Unlock()
+	w.Logf("attempt %d/%d failed: %s", a.number, a.of, msg)
+	return true
+}
+
+// stopRecorded stops the test, or the current attempt, after a fatal
+// failure was recorded by recordFailure
+func (w *W[T]) stopRecorded(msg string) {
+	if w.recording() == nil {
+		// Quarantined: the failure was downgraded, so unlike exitGoroutine
+		// leave the rest of the test running with its context intact
+		if w.onTestGoroutine() {
+			w.tb.SkipNow()
+		}
+		runtime.Goexit()
+	}
+	if w.onTestGoroutine() {
+		panic(abortAttempt{})
+	}
+	w.cancel(fmt.Errorf("%w: %s", ErrFailed, msg))
+	runtime.Goexit()
+}
+
+// finalizeAttempt makes the attempt the last one, reporting the failures
+// it recorded so far
+func (w *W[T]) finalizeAttempt() {
+	a := w.recording()
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.final = true
+	failed := a.failed
+	a.mu.Unlock()
+	for _, msg := range failed {
+		w.Error(msg)
+	}
+}
+
+func (a *retryAttempt) isFinal() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.final
+}
+
+func (a *retryAttempt) failures() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.failed
+}
+
+func (a *retryAttempt) addCleanup(fn func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cleanups = append(a.cleanups, fn)
+}
+
+// runCleanups runs the attempt's cleanup functions in last-in, first-out
+// order, like the testing package does
+func (a *retryAttempt) runCleanups() {
+	for {
+		a.mu.Lock()
+		if len(a.cleanups) == 0 {
+			a.mu.Unlock()
+			return
+		}
+		fn := a.cleanups[len(a.cleanups)-1]
+		a.cleanups = a.cleanups[:len(a.cleanups)-1]
+		a.mu.Unlock()
+		runCleanup(fn)
+	}
+}
+
+// runCleanup runs a cleanup function, which may stop early if it fails
+// fatally
+func runCleanup(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(abortAttempt); !ok {
+				panic(r)
+			}
+		}
+	}()
+	fn()
+}
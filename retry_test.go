@@ -0,0 +1,157 @@
+package testctx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+	var events []string
+	ctx := testctx.ContextWithEventHandler(context.Background(), func(_ context.Context, name string, attrs ...testctx.Attr) {
+		events = append(events, name)
+	})
+	tt := testctx.New(t,
+		testctx.WithLogRecorder[*testing.T](rec),
+		testctx.WithRetry[*testing.T](3),
+	).WithContext(ctx)
+
+	var steps []string
+	passed := tt.Run("flaky", func(ctx context.Context, t *testctx.T) {
+		attempt := testctx.Attempt(ctx)
+		steps = append(steps, fmt.Sprintf("attempt %d", attempt))
+		t.Cleanup(func() {
+			steps = append(steps, fmt.Sprintf("cleanup %d", attempt))
+		})
+		switch attempt {
+		case 1:
+			t.Errorf("first try")
+			assert.True(t, t.Failed())
+		case 2:
+			require.Fail(t, "second try")
+			steps = append(steps, "unreachable")
+		}
+	})
+
+	assert.True(t, passed)
+	assert.Equal(t, []string{
+		"attempt 1", "cleanup 1",
+		"attempt 2", "cleanup 2",
+		"attempt 3", "cleanup 3",
+	}, steps)
+	assert.True(t, rec.Contains("attempt 1/3 failed: first try"))
+	assert.True(t, rec.Contains("second try"))
+	assert.True(t, rec.Contains("test is flaky: passed on attempt 3/3"))
+	assert.Zero(t, rec.Count(testctx.LogKindError))
+	assert.Equal(t, []string{testctx.FlakyEvent}, events)
+}
+
+func TestRetryGo(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+	tt := testctx.New(t,
+		testctx.WithLogRecorder[*testing.T](rec),
+		testctx.WithRetry[*testing.T](3),
+	)
+
+	var steps []string
+	var mu sync.Mutex
+	step := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		steps = append(steps, s)
+	}
+	passed := tt.Run("flaky", func(ctx context.Context, t *testctx.T) {
+		attempt := testctx.Attempt(ctx)
+		t.Cleanup(func() {
+			step(fmt.Sprintf("cleanup %d", attempt))
+		})
+		t.Go(func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			step(fmt.Sprintf("goroutine %d", attempt))
+			if attempt == 1 {
+				return errors.New("goroutine failed")
+			}
+			return nil
+		})
+	})
+
+	// Each attempt waits for its own goroutines before its cleanups run
+	assert.True(t, passed)
+	assert.Equal(t, []string{
+		"goroutine 1", "cleanup 1",
+		"goroutine 2", "cleanup 2",
+	}, steps)
+	assert.True(t, rec.Contains("attempt 1/3 failed: goroutine started at"))
+	assert.True(t, rec.Contains("test is flaky: passed on attempt 2/3"))
+}
+
+func TestRetryParallel(t *testing.T) {
+	var attempts []int
+	t.Cleanup(func() {
+		assert.Equal(t, []int{1, 2}, attempts)
+	})
+
+	tt := testctx.New(t,
+		testctx.WithRetry[*testing.T](2),
+		testctx.WithParallel(),
+	)
+	tt.Run("flaky", func(ctx context.Context, t *testctx.T) {
+		attempts = append(attempts, testctx.Attempt(ctx))
+		if testctx.Attempt(ctx) == 1 {
+			t.Error("first try")
+		}
+	})
+}
+
+func TestRetryPassing(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+	tt := testctx.New(t,
+		testctx.WithLogRecorder[*testing.T](rec),
+		testctx.WithRetry[*testing.T](3),
+	)
+
+	var attempts []int
+	tt.Run("passes", func(ctx context.Context, t *testctx.T) {
+		attempts = append(attempts, testctx.Attempt(ctx))
+	})
+
+	assert.Equal(t, []int{1}, attempts)
+	assert.False(t, rec.Contains("flaky"))
+}
+
+func TestRetryFailing(t *testing.T) {
+	if inSubprocess() {
+		tt := testctx.New(t, testctx.WithRetry[*testing.T](2))
+
+		tt.Run("always-fails", func(ctx context.Context, t *testctx.T) {
+			t.Errorf("failure %d", testctx.Attempt(ctx))
+		})
+
+		tt.Run("with-subtests", func(ctx context.Context, t *testctx.T) {
+			t.Errorf("before subtest %d", testctx.Attempt(ctx))
+			t.Run("child", func(ctx context.Context, t *testctx.T) {})
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestRetryFailing")
+	assert.False(t, passed)
+	assert.Contains(t, out, "--- FAIL: TestRetryFailing/always-fails")
+	assert.Contains(t, out, "attempt 1/2 failed: failure 1")
+	assert.Regexp(t, `\.go:\d+: failure 2`, out)
+	assert.NotRegexp(t, `\.go:\d+: failure 1`, out)
+
+	// Subtests can't be retried, so the first attempt is the last
+	assert.Contains(t, out, "--- FAIL: TestRetryFailing/with-subtests")
+	assert.Contains(t, out, "--- PASS: TestRetryFailing/with-subtests/child")
+	assert.Regexp(t, `\.go:\d+: before subtest 1`, out)
+	assert.NotContains(t, out, "before subtest 2")
+}
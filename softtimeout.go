@@ -0,0 +1,73 @@
+package testctx
+
+import (
+	"context"
+	"runtime/pprof"
+	"time"
+)
+
+// SoftTimeoutEvent is the name of the event emitted (see Event) each time
+// WithSoftTimeout warns about a slow test
+const SoftTimeoutEvent = "testctx.soft_timeout"
+
+// SoftTimeoutConfig holds configuration for the WithSoftTimeout middleware
+type SoftTimeoutConfig struct {
+	// Interval between warnings once the soft timeout has passed. Defaults
+	// to the soft timeout itself.
+	Interval time.Duration
+}
+
+// WithSoftTimeout creates middleware that warns when a test runs longer than
+// d, without canceling anything. Once d has passed, and then at every
+// interval until the test function returns, it logs how long the test has
+// been running along with a sample of the stacks of the test's goroutines,
+// and emits a SoftTimeoutEvent. Place it after tracing middleware so that
+// the events are recorded on the test's span. Like WithTimeout, d is
+// subject to the timeout policy; see ScaleTimeout.
+func WithSoftTimeout[T Runner[T]](d time.Duration, cfg ...SoftTimeoutConfig) Middleware[T] {
+	var c SoftTimeoutConfig
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	d = ScaleTimeout(d)
+	if c.Interval <= 0 {
+		c.Interval = d
+	}
+
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			start := time.Now()
+			stop := make(chan struct{})
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				// Don't show up in the sampled stacks ourselves
+				pprof.SetGoroutineLabels(context.Background())
+
+				timer := time.NewTimer(d)
+				defer timer.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-timer.C:
+					}
+					elapsed := time.Since(start).Round(time.Millisecond)
+					stacks := testGoroutineDump(w.state)
+					w.Logf("test has been running for %s (soft timeout %s); currently at:\n\n%s", elapsed, d, stacks)
+					Event(ctx, SoftTimeoutEvent,
+						Attr{Key: "elapsed", Value: elapsed.String()},
+						Attr{Key: "soft_timeout", Value: d.String()},
+						Attr{Key: "stack", Value: stacks},
+					)
+					timer.Reset(c.Interval)
+				}
+			}()
+			defer func() {
+				close(stop)
+				<-stopped
+			}()
+			next(ctx, w)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package testctx_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftTimeout(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+
+	var mu sync.Mutex
+	var events []string
+	ctx := testctx.ContextWithEventHandler(context.Background(), func(ctx context.Context, name string, attrs ...testctx.Attr) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, name)
+	})
+
+	d := testctx.ScaleTimeout(50 * time.Millisecond)
+	tt := testctx.New(t, testctx.WithSoftTimeout[*testing.T](50*time.Millisecond, testctx.SoftTimeoutConfig{
+		Interval: 2 * d,
+	})).WithLogger(rec)
+	tt = tt.WithContext(ctx)
+
+	tt.Run("slow", func(ctx context.Context, t *testctx.T) {
+		time.Sleep(4 * d)
+	})
+	tt.Run("fast", func(ctx context.Context, t *testctx.T) {})
+
+	// Warned after d and 3*d
+	msgs := rec.Match(`^test has been running for \S+ \(soft timeout ` + d.String() + `\); currently at:`)
+	assert.Len(t, msgs, 2)
+	assert.True(t, rec.Contains("TestSoftTimeout.func"), "warning should include a stack sample")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{testctx.SoftTimeoutEvent, testctx.SoftTimeoutEvent}, events)
+}
@@ -0,0 +1,164 @@
+package testctx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scopeKey identifies a subtree of tests governed by a single instance of a
+// middleware that is applied at every level (see WithSuiteTimeout). Its
+// non-zero size guarantees distinct allocations have distinct addresses.
+type scopeKey struct{ _ byte }
+
+// WithSuiteTimeout creates middleware that bounds the total time taken by
+// the subtree of tests it is first applied to, including time spent waiting
+// for parallel subtests. Since middleware is applied at every level, the
+// outermost application (e.g. around the RunTests loop) defines the suite,
+// and nested applications merely track the tests running within it.
+//
+// When the timeout fires, the context of every test in the suite is
+// canceled, every test still running is marked failed, and the suite's root
+// test reports which tests were still running. Tests that had not started
+// yet fail immediately once they do. d is subject to the timeout
+// policy; see ScaleTimeout.
+func WithSuiteTimeout[T Runner[T]](d time.Duration) Middleware[T] {
+	key := &scopeKey{}
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			if s, ok := ctx.Value(key).(*suiteTimeout[T]); ok {
+				if err := s.expired(); err != nil {
+					// Started after the suite timed out, e.g. while waiting
+					// to run in parallel
+					w.Error(err)
+					return
+				}
+				s.track(w)
+				next(ctx, w)
+				return
+			}
+
+			d := ScaleTimeout(d)
+			ctx, cancel := context.WithCancelCause(ctx)
+			s := &suiteTimeout[T]{
+				root:    &suiteTest[T]{w: w},
+				d:       d,
+				cancel:  cancel,
+				running: map[*testState]*suiteTest[T]{},
+			}
+			timer := time.AfterFunc(d, s.expire)
+			w.Cleanup(func() {
+				timer.Stop()
+				s.finish()
+				cancel(nil)
+			})
+			next(context.WithValue(ctx, key, s), w)
+		}
+	}
+}
+
+// suiteTimeout tracks the tests running within a suite
+type suiteTimeout[T Runner[T]] struct {
+	root   *suiteTest[T]
+	d      time.Duration
+	cancel context.CancelCauseFunc
+
+	mu       sync.Mutex
+	running  map[*testState]*suiteTest[T]
+	err      error
+	finished bool
+}
+
+// suiteTest is a test that may be reported when the suite times out
+type suiteTest[T Runner[T]] struct {
+	w *W[T]
+
+	mu sync.Mutex
+	// done is set once the test completes, after which it can no longer
+	// be reported on
+	done bool
+}
+
+// report calls fn unless the test has completed, keeping it from
+// completing in the meantime
+func (t *suiteTest[T]) report(fn func(w *W[T])) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.done {
+		fn(t.w)
+	}
+}
+
+// complete marks the test completed, waiting for any report in progress
+func (t *suiteTest[T]) complete() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done = true
+}
+
+// track records w as running until it completes
+func (s *suiteTimeout[T]) track(w *W[T]) {
+	test := &suiteTest[T]{w: w}
+	s.mu.Lock()
+	s.running[w.state] = test
+	s.mu.Unlock()
+	w.Cleanup(func() {
+		s.mu.Lock()
+		delete(s.running, w.state)
+		s.mu.Unlock()
+		test.complete()
+	})
+}
+
+// expired returns the cause of the suite's cancellation if it timed out
+func (s *suiteTimeout[T]) expired() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// finish marks the suite as completed, after which it can no longer expire
+func (s *suiteTimeout[T]) finish() {
+	s.mu.Lock()
+	s.finished = true
+	s.mu.Unlock()
+	s.root.complete()
+}
+
+func (s *suiteTimeout[T]) expire() {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	cause := fmt.Errorf("suite timeout of %s exceeded", s.d)
+	s.err = cause
+	s.cancel(cause)
+	running := make([]*suiteTest[T], 0, len(s.running))
+	for _, test := range s.running {
+		running = append(running, test)
+	}
+	s.mu.Unlock()
+
+	// Reporting a failure runs the test's loggers and failure hooks, so
+	// it's done outside the suite's lock; each test is only kept from
+	// completing until its own report is done
+	var names []string
+	for _, test := range running {
+		test.report(func(w *W[T]) {
+			names = append(names, w.Name())
+			w.Error(cause)
+		})
+	}
+	slices.Sort(names)
+	s.root.report(func(root *W[T]) {
+		if len(names) == 0 {
+			root.Error(cause)
+			return
+		}
+		root.Errorf("%s; tests still running:\n%s", cause, strings.Join(names, "\n"))
+	})
+}
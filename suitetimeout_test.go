@@ -0,0 +1,57 @@
+package testctx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+type SlowSuite struct{}
+
+func (SlowSuite) TestFast(ctx context.Context, t *testctx.T) {}
+
+func (SlowSuite) TestSlow(ctx context.Context, t *testctx.T) {
+	<-ctx.Done()
+	t.Logf("canceled: %v", context.Cause(ctx))
+}
+
+func (SlowSuite) TestSlower(ctx context.Context, t *testctx.T) {
+	<-ctx.Done()
+}
+
+func TestSuiteTimeout(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t,
+			testctx.WithParallel(),
+			testctx.WithTimeout[*testing.T](time.Minute),
+			testctx.WithSuiteTimeout[*testing.T](200*time.Millisecond),
+		).RunTests(SlowSuite{})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestSuiteTimeout")
+	assert.False(t, passed)
+	timeout := testctx.ScaleTimeout(200 * time.Millisecond).String()
+	assert.Contains(t, out, "canceled: suite timeout of "+timeout+" exceeded")
+	assert.Contains(t, out, "suite timeout of "+timeout+" exceeded; tests still running:\n"+
+		"        TestSuiteTimeout/TestSlow\n"+
+		"        TestSuiteTimeout/TestSlower\n")
+	assert.Contains(t, out, "--- PASS: TestSuiteTimeout/TestFast")
+	assert.Contains(t, out, "--- FAIL: TestSuiteTimeout/TestSlow ")
+	assert.Contains(t, out, "--- FAIL: TestSuiteTimeout/TestSlower ")
+}
+
+func TestSuiteTimeoutPasses(t *testing.T) {
+	testctx.New(t,
+		testctx.WithParallel(),
+		testctx.WithSuiteTimeout[*testing.T](time.Minute),
+	).RunTests(SuiteWithoutSlowTests{})
+}
+
+type SuiteWithoutSlowTests struct{}
+
+func (SuiteWithoutSlowTests) TestA(ctx context.Context, t *testctx.T) {}
+func (SuiteWithoutSlowTests) TestB(ctx context.Context, t *testctx.T) {}
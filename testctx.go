@@ -5,10 +5,17 @@ package testctx
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"reflect"
+	"runtime"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // Common type aliases for convenience
@@ -48,6 +55,11 @@ type W[T Runner[T]] struct {
 	ctx        context.Context
 	middleware []Middleware[T]
 	loggers    MultiLogger
+	state      *testState
+	logPrefix  func() string
+	detached   *atomic.Bool
+	attempt    *retryAttempt
+	quarantine *quarantine
 
 	// we have to embed testing.TB to become a testing.TB ourselves,
 	// since it has a private method
@@ -69,17 +81,20 @@ type RunFunc[T Runner[T]] func(context.Context, *W[T])
 //   - Middleware support for test instrumentation
 //   - Logging interception via WithLogger
 //
-// The context is automatically canceled when the test completes.
+// The context is automatically canceled when the test completes, or when the
+// test binary is interrupted (see HandleInterrupts).
 // See Using() for details on middleware behavior.
 func New[T Runner[T]](t T, middleware ...Middleware[T]) *W[T] {
-	ctx, cancel := context.WithCancel(context.Background())
-	t.Cleanup(cancel)
-	return &W[T]{
+	state, ctx := startTest(t, nil, rootContext())
+	w := &W[T]{
 		TB:         t,
 		tb:         t,
 		ctx:        ctx,
 		middleware: middleware,
+		state:      state,
 	}
+	reportVia(w)
+	return w
 }
 
 // Using adds middleware to the wrapper. Middleware are executed in a nested pattern:
@@ -137,14 +152,48 @@ func (w *W[T]) WithContext(ctx context.Context) *W[T] {
 	return clone
 }
 
+// CleanupTimeout bounds the context passed to functions registered via
+// CleanupCtx. It is subject to the timeout policy; see ScaleTimeout.
+var CleanupTimeout = time.Minute
+
+// CleanupCtx registers a function to be called when the test completes, like
+// Cleanup. The function receives a context that carries the values of the
+// current test context (trace span, loggers, etc.) but is not canceled along
+// with it, so teardown calls don't fail just because the test has finished
+// or timed out. Instead, it is canceled after CleanupTimeout.
+func (w *W[T]) CleanupCtx(fn func(ctx context.Context)) {
+	ctx := context.WithoutCancel(w.ctx)
+	w.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(ctx, ScaleTimeout(CleanupTimeout))
+		defer cancel()
+		fn(ctx)
+	})
+}
+
 // Run runs a subtest with the given name and function. The function will be wrapped
 // by any middleware registered via Using() or New(), with middleware executing in
 // the order described by Using().
 func (w *W[T]) Run(name string, fn RunFunc[T]) bool {
+	return w.run(name, fn, nil)
+}
+
+// run is like Run, but calls started (if non-nil) with the subtest's
+// wrapper before any middleware runs, i.e. only if the subtest isn't
+// filtered out.
+func (w *W[T]) run(name string, fn RunFunc[T], started func(*W[T])) bool {
+	if w.isDetached() {
+		return false
+	}
+	w.finalizeAttempt()
 	return w.tb.Run(name, func(t T) {
 		newW := w.clone()
 		newW.tb = t
 		newW.TB = t
+		newW.attempt = nil
+		newW.state, newW.ctx = startTest(t, w.state, w.ctx)
+		if started != nil {
+			started(newW)
+		}
 
 		wrapped := w.wrapWithMiddleware(fn)
 		wrapped(newW.ctx, newW)
@@ -155,6 +204,7 @@ func (w *W[T]) Run(name string, fn RunFunc[T]) bool {
 // of all test log messages (Log, Logf), errors (Error, Errorf), fatal errors
 // (Fatal, Fatalf), and skip notifications (Skip, Skipf). This allows test output
 // to be captured or redirected while still maintaining the original test behavior.
+// Registered secrets (see Redact) are masked before messages reach the logger.
 func (w *W[T]) WithLogger(l Logger) *W[T] {
 	clone := w.clone()
 	clone.loggers = append(clone.loggers, l)
@@ -163,6 +213,14 @@ func (w *W[T]) WithLogger(l Logger) *W[T] {
 
 // Error calls through to the underlying test/benchmark type and logs if a logger is set
 func (w *W[T]) Error(args ...any) {
+	if w.isDetached() {
+		return
+	}
+	if w.recordFailure(sprintln(args...)) {
+		return
+	}
+	w.notifyFailure(sprintln(args...))
+	args = w.messageArgs(args)
 	w.tb.Error(args...)
 	if w.loggers != nil {
 		w.loggers.Error(args...)
@@ -171,30 +229,153 @@ func (w *W[T]) Error(args ...any) {
 
 // Errorf calls through to the underlying test/benchmark type and logs if a logger is set
 func (w *W[T]) Errorf(format string, args ...any) {
+	if w.isDetached() {
+		return
+	}
+	if w.recordFailure(fmt.Sprintf(format, args...)) {
+		return
+	}
+	w.notifyFailure(fmt.Sprintf(format, args...))
+	format, args = w.messagefArgs(format, args)
 	w.tb.Errorf(format, args...)
 	if w.loggers != nil {
 		w.loggers.Errorf(format, args...)
 	}
 }
 
-// Fatal calls through to the underlying test/benchmark type and logs if a logger is set
+// Fatal calls through to the underlying test/benchmark type and logs if a logger is set.
+// See FailNow for its behavior when called from a goroutine other than the test goroutine.
 func (w *W[T]) Fatal(args ...any) {
+	if w.isDetached() {
+		runtime.Goexit()
+	}
+	if w.recordFailure(sprintln(args...)) {
+		w.stopRecorded(sprintln(args...))
+	}
+	w.notifyFailure(sprintln(args...))
+	args = w.messageArgs(args)
 	if w.loggers != nil {
 		w.loggers.Error(args...)
 	}
+	if !w.onTestGoroutine() {
+		w.tb.Error(args...)
+		w.exitGoroutine(sprintln(args...))
+	}
 	w.tb.Fatal(args...)
 }
 
-// Fatalf calls through to the underlying test/benchmark type and logs if a logger is set
+// Fatalf calls through to the underlying test/benchmark type and logs if a logger is set.
+// See FailNow for its behavior when called from a goroutine other than the test goroutine.
 func (w *W[T]) Fatalf(format string, args ...any) {
+	if w.isDetached() {
+		runtime.Goexit()
+	}
+	if w.recordFailure(fmt.Sprintf(format, args...)) {
+		w.stopRecorded(fmt.Sprintf(format, args...))
+	}
+	w.notifyFailure(fmt.Sprintf(format, args...))
+	format, args = w.messagefArgs(format, args)
 	if w.loggers != nil {
 		w.loggers.Errorf(format, args...)
 	}
+	if !w.onTestGoroutine() {
+		w.tb.Errorf(format, args...)
+		w.exitGoroutine(fmt.Sprintf(format, args...))
+	}
 	w.tb.Fatalf(format, args...)
 }
 
+// FailNow calls through to the underlying test/benchmark type. Like Fatal
+// and Fatalf, it is safe to call from goroutines other than the test
+// goroutine: instead of leaving the test running in an undefined state, the
+// test is marked failed, its context is canceled, and the calling goroutine
+// exits.
+func (w *W[T]) FailNow() {
+	if w.isDetached() {
+		runtime.Goexit()
+	}
+	if w.recording() != nil || w.quarantine != nil {
+		// Usually a failure was just recorded, e.g. by require
+		if !w.Failed() && (w.quarantine == nil || !w.quarantine.hasFailed()) {
+			w.recordFailure("FailNow called")
+		}
+		w.stopRecorded("FailNow called")
+	}
+	if !w.onTestGoroutine() {
+		w.tb.Fail()
+		w.exitGoroutine("FailNow called")
+	}
+	w.tb.FailNow()
+}
+
+// Fail calls through to the underlying test/benchmark type, unless the
+// failure is recorded by WithRetry or WithQuarantine
+func (w *W[T]) Fail() {
+	if w.isDetached() || w.recordFailure("Fail called") {
+		return
+	}
+	w.tb.Fail()
+}
+
+// Failed reports whether the test has failed, including failures recorded
+// by WithRetry during the current attempt
+func (w *W[T]) Failed() bool {
+	if a := w.recording(); a != nil && len(a.failures()) > 0 {
+		return true
+	}
+	return w.tb.Failed()
+}
+
+// Cleanup registers a function to be called when the test completes. During
+// an attempt of a test run with WithRetry, it is called when the attempt
+// completes instead.
+func (w *W[T]) Cleanup(fn func()) {
+	if w.isDetached() {
+		return
+	}
+	if w.attempt != nil {
+		w.attempt.addCleanup(fn)
+		return
+	}
+	w.tb.Cleanup(fn)
+}
+
+// onTestGoroutine reports whether the caller is running on the test goroutine
+func (w *W[T]) onTestGoroutine() bool {
+	return currentGoroutineID() == w.state.goroutine
+}
+
+// ErrFailed is wrapped by the cause of test context cancellation when the
+// test fails: when it fails fatally on a goroutine other than the test
+// goroutine (see FailNow), or fails at all under WithCancelOnFailure
+var ErrFailed = errors.New("test failed")
+
+// exitGoroutine handles a fatal failure on a goroutine other than the test
+// goroutine, where calling through to the underlying FailNow would stop the
+// wrong goroutine and leave the test running. The failure must already have
+// been recorded; the test context is canceled with the failure as its
+// cause, and the calling goroutine exits.
+func (w *W[T]) exitGoroutine(msg string) {
+	w.cancel(fmt.Errorf("%w: %s", ErrFailed, msg))
+	runtime.Goexit()
+}
+
+// cancel cancels the test context with the given cause, or only the
+// context of the current attempt if it may still be retried (see WithRetry)
+func (w *W[T]) cancel(cause error) {
+	if a := w.recording(); a != nil {
+		a.cancel(cause)
+		return
+	}
+	w.state.cancel(cause)
+}
+
 // Log calls through to the underlying test/benchmark type and logs if a logger is set
 func (w *W[T]) Log(args ...any) {
+	if w.isDetached() {
+		return
+	}
+	args = w.messageArgs(args)
 	w.tb.Log(args...)
 	if w.loggers != nil {
 		w.loggers.Log(args...)
@@ -203,6 +384,10 @@ func (w *W[T]) Log(args ...any) {
 
 // Logf calls through to the underlying test/benchmark type and logs if a logger is set
 func (w *W[T]) Logf(format string, args ...any) {
+	if w.isDetached() {
+		return
+	}
+	format, args = w.messagefArgs(format, args)
 	w.tb.Logf(format, args...)
 	if w.loggers != nil {
 		w.loggers.Logf(format, args...)
@@ -211,6 +396,10 @@ func (w *W[T]) Logf(format string, args ...any) {
 
 // Skip calls through to the underlying test/benchmark type and logs if a logger is set
 func (w *W[T]) Skip(args ...any) {
+	if w.isDetached() {
+		runtime.Goexit()
+	}
+	args = w.messageArgs(args)
 	if w.loggers != nil {
 		w.loggers.Log(args...)
 	}
@@ -219,6 +408,10 @@ func (w *W[T]) Skip(args ...any) {
 
 // Skipf calls through to the underlying test/benchmark type and logs if a logger is set
 func (w *W[T]) Skipf(format string, args ...any) {
+	if w.isDetached() {
+		runtime.Goexit()
+	}
+	format, args = w.messagefArgs(format, args)
 	if w.loggers != nil {
 		w.loggers.Logf(format, args...)
 	}
@@ -239,29 +432,31 @@ func (w *W[T]) RunBenchmarks(containers ...any) {
 func (w *W[T]) runMethods(containers []any, prefix string) {
 	wrapped := w.wrapWithMiddleware(func(ctx context.Context, t *W[T]) {
 		for _, container := range containers {
-			containerType := reflect.TypeOf(container)
 			containerValue := reflect.ValueOf(container)
+			methods := suiteMethods[T](container, prefix)
 
-			for i := range containerType.NumMethod() {
-				method := containerType.Method(i)
-				if !strings.HasPrefix(method.Name, prefix) {
-					continue
-				}
-
-				methodType := method.Type
-				if methodType.NumIn() != 3 || // receiver + context + W[T]
-					!methodType.In(1).AssignableTo(reflect.TypeOf((*context.Context)(nil)).Elem()) ||
-					!methodType.In(2).AssignableTo(reflect.TypeOf((*W[T])(nil))) {
-					continue
-				}
+			var deps *dependencies[T]
+			if prefix == "Test" {
+				deps = newDependencies(t, container, methods)
+			}
+			if deps != nil {
+				methods = deps.order
+			}
 
-				t.Run(method.Name, func(ctx context.Context, t *W[T]) {
+			for _, method := range methods {
+				run := func(ctx context.Context, t *W[T]) {
 					method.Func.Call([]reflect.Value{
 						containerValue,
 						reflect.ValueOf(ctx),
 						reflect.ValueOf(t),
 					})
-				})
+				}
+				mt := t.withTimeoutOverrides(container, method.Name)
+				if deps != nil {
+					deps.run(mt, method.Name, run)
+				} else {
+					mt.Run(method.Name, run)
+				}
 			}
 		}
 	})
@@ -269,6 +464,28 @@ func (w *W[T]) runMethods(containers []any, prefix string) {
 	wrapped(w.ctx, w)
 }
 
+// suiteMethods returns the methods of the container with the given prefix
+// and the signature of a RunFunc
+func suiteMethods[T Runner[T]](container any, prefix string) []reflect.Method {
+	containerType := reflect.TypeOf(container)
+	var methods []reflect.Method
+	for i := range containerType.NumMethod() {
+		method := containerType.Method(i)
+		if !strings.HasPrefix(method.Name, prefix) {
+			continue
+		}
+
+		methodType := method.Type
+		if methodType.NumIn() != 3 || // receiver + context + W[T]
+			!methodType.In(1).AssignableTo(reflect.TypeOf((*context.Context)(nil)).Elem()) ||
+			!methodType.In(2).AssignableTo(reflect.TypeOf((*W[T])(nil))) {
+			continue
+		}
+		methods = append(methods, method)
+	}
+	return methods
+}
+
 // clone creates a shallow copy of the wrapper with all fields preserved
 func (w *W[T]) clone() *W[T] {
 	return &W[T]{
@@ -277,6 +494,139 @@ func (w *W[T]) clone() *W[T] {
 		ctx:        w.ctx,
 		middleware: slices.Clone(w.middleware),
 		loggers:    slices.Clone(w.loggers),
+		state:      w.state,
+		logPrefix:  w.logPrefix,
+		detached:   w.detached,
+		attempt:    w.attempt,
+		quarantine: w.quarantine,
+	}
+}
+
+// notifyFailure calls the test's failure hooks with the redacted message
+func (w *W[T]) notifyFailure(msg string) {
+	w.state.mu.Lock()
+	hooks := slices.Clone(w.state.failureHooks)
+	w.state.mu.Unlock()
+	if len(hooks) == 0 {
+		return
+	}
+	if r := w.redactor(); r != nil {
+		msg = r.Replace(msg)
+	}
+	for _, hook := range hooks {
+		hook(msg)
+	}
+}
+
+// sprintln formats args the way testing.T formats Log arguments
+func sprintln(args ...any) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+// isDetached reports whether the wrapper belongs to a test function that
+// was abandoned (see WithHardTimeout). Calls into the underlying test from
+// an abandoned function would panic once the test has completed, so they
+// are dropped instead.
+func (w *W[T]) isDetached() bool {
+	return w.detached != nil && w.detached.Load()
+}
+
+// messageArgs prepares Log-style arguments for the underlying test/benchmark
+// and loggers. If there is nothing to redact or prefix the arguments are
+// returned unchanged; otherwise they are formatted the same way testing.T
+// formats them and returned as a single rewritten message.
+func (w *W[T]) messageArgs(args []any) []any {
+	r := w.redactor()
+	if r == nil && w.logPrefix == nil {
+		return args
+	}
+	return []any{w.rewrite(r, sprintln(args...))}
+}
+
+// messagefArgs is like messageArgs but for Logf-style arguments. Secrets are
+// masked in the formatted result, so they are caught even when they are
+// assembled from multiple arguments.
+func (w *W[T]) messagefArgs(format string, args []any) (string, []any) {
+	r := w.redactor()
+	if r == nil && w.logPrefix == nil {
+		return format, args
+	}
+	return "%s", []any{w.rewrite(r, fmt.Sprintf(format, args...))}
+}
+
+// rewrite masks secrets in msg and prepends the log prefix, if any
+func (w *W[T]) rewrite(r *strings.Replacer, msg string) string {
+	if r != nil {
+		msg = r.Replace(msg)
+	}
+	if w.logPrefix != nil {
+		msg = w.logPrefix() + msg
+	}
+	return msg
+}
+
+// startTest sets up the state of a test running on the calling goroutine.
+// It returns the state along with the test's context, derived from ctx and
+// canceled when the test completes.
+func startTest(t testing.TB, parent *testState, ctx context.Context) (*testState, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	t.Cleanup(func() { cancel(nil) })
+	s := newTestState(parent)
+	s.cancel = cancel
+	s.goroutine = currentGoroutineID()
+	s.labelGoroutine()
+	liveTests.track(t, s)
+	return s, ctx
+}
+
+// testState holds per-test state shared by every wrapper of the same test,
+// regardless of which clone (via Using, WithContext, WithLogger) it is
+// accessed through. Subtests get their own state linked to their parent's.
+type testState struct {
+	// id uniquely identifies the test within the process
+	id     int64
+	parent *testState
+	// goroutine is the ID of the test goroutine
+	goroutine int64
+	// cancel cancels the test context
+	cancel context.CancelCauseFunc
+	// parallel is set once WithParallel has marked the test parallel
+	parallel atomic.Bool
+
+	mu           sync.Mutex
+	secrets      []string
+	attrs        []Attr
+	failureHooks []func(msg string)
+	group        *goGroup
+	// output is the writer returned by Output, created on first use
+	output io.Writer
+}
+
+// onFailure registers fn to be called with the message of every failure
+// reported through W (Error, Errorf, Fatal, Fatalf)
+func (s *testState) onFailure(fn func(msg string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failureHooks = append(s.failureHooks, fn)
+}
+
+// isAncestorOf reports whether other is s or one of its subtests
+func (s *testState) isAncestorOf(other *testState) bool {
+	for ; other != nil; other = other.parent {
+		if other == s {
+			return true
+		}
+	}
+	return false
+}
+
+// lastTestID is used to allocate testState IDs
+var lastTestID atomic.Int64
+
+func newTestState(parent *testState) *testState {
+	return &testState{
+		id:     lastTestID.Add(1),
+		parent: parent,
 	}
 }
 
@@ -293,7 +643,10 @@ func lastSlashIndex(s string) int {
 func (w *W[T]) wrapWithMiddleware(fn RunFunc[T]) RunFunc[T] {
 	// First wrap the function to ensure context sync
 	wrapped := func(ctx context.Context, t *W[T]) {
-		fn(ctx, t.WithContext(ctx))
+		t = t.WithContext(ctx)
+		// Report interrupts with the loggers added by middleware
+		reportVia(t)
+		fn(ctx, t)
 	}
 
 	// Walk the middleware in reverse order so the last middleware added
@@ -2,7 +2,12 @@ package testctx_test
 
 import (
 	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dagger/testctx"
 	"github.com/stretchr/testify/assert"
@@ -178,3 +183,84 @@ func TestMiddlewareDynamicAddition(t *testing.T) {
 		"parent 2", // parent 2 test execution
 	}, order)
 }
+
+// subprocessEnv is set when the test binary re-executes itself via runSubprocess
+const subprocessEnv = "TESTCTX_SUBPROCESS"
+
+// inSubprocess reports whether the current test was started by runSubprocess,
+// for tests that need to observe failures without failing themselves.
+func inSubprocess() bool {
+	return os.Getenv(subprocessEnv) != ""
+}
+
+// runSubprocess re-runs the named test in a subprocess, with any additional
+// environment variables, and returns its verbose output and whether it
+// passed. The name is a top-level test, optionally followed by the subtests
+// to run within it, e.g. "TestSuite/TestCase".
+func runSubprocess(t *testing.T, name string, env ...string) (string, bool) {
+	t.Helper()
+	out, passed, err := startSubprocess(name, env...)
+	if err != nil {
+		t.Fatalf("failed to run subprocess: %v", err)
+	}
+	return out, passed
+}
+
+// startSubprocess is like runSubprocess, but returns an error if the
+// subprocess couldn't be run instead of failing the test, so that it can be
+// called from other goroutines.
+func startSubprocess(name string, env ...string) (string, bool, error) {
+	pattern := "^" + strings.ReplaceAll(name, "/", "$/^") + "$"
+	cmd := exec.Command(os.Args[0], "-test.run="+pattern, "-test.count=1", "-test.v", "-test.parallel=8")
+	cmd.Env = append(os.Environ(), subprocessEnv+"=1")
+	cmd.Env = append(cmd.Env, env...)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", false, err
+	}
+	return string(out), err == nil, nil
+}
+
+func TestCleanupCtx(t *testing.T) {
+	type ctxKey struct{}
+
+	tt := testctx.New(t, testctx.WithTimeout[*testing.T](time.Minute))
+	tt.Run("subtest", func(ctx context.Context, t *testctx.T) {
+		ctx, cancel := context.WithCancel(context.WithValue(ctx, ctxKey{}, "value"))
+		t = t.WithContext(ctx)
+		t.CleanupCtx(func(ctx context.Context) {
+			assert.NoError(t, ctx.Err(), "cleanup context should not be canceled")
+			assert.Equal(t, "value", ctx.Value(ctxKey{}))
+
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok, "cleanup context should have a deadline")
+			assert.WithinDuration(t, time.Now().Add(testctx.ScaleTimeout(testctx.CleanupTimeout)), deadline, time.Second)
+		})
+		cancel()
+	})
+}
+
+func TestFatalFromGoroutine(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t).Run("fatal", func(ctx context.Context, t *testctx.T) {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				t.Fatalf("fatal from goroutine: %d", 42)
+				t.Log("unreachable")
+			}()
+			<-done
+			<-ctx.Done()
+			t.Logf("test continued; canceled: %v", context.Cause(ctx))
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestFatalFromGoroutine")
+	assert.False(t, passed)
+	assert.Contains(t, out, "fatal from goroutine: 42")
+	assert.NotContains(t, out, "unreachable")
+	assert.Contains(t, out, "test continued; canceled: test failed: fatal from goroutine: 42")
+	assert.Contains(t, out, "--- FAIL: TestFatalFromGoroutine/fatal")
+}
@@ -0,0 +1,96 @@
+package testctx
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TimeoutScaleEnv is the environment variable holding a global factor that
+// all timeouts are multiplied by, e.g. TESTCTX_TIMEOUT_SCALE=2.5 on slow CI
+// runners. Missing, invalid, or non-positive values are ignored.
+const TimeoutScaleEnv = "TESTCTX_TIMEOUT_SCALE"
+
+// RaceTimeoutScale is an additional factor that timeouts are multiplied by
+// when the race detector is enabled
+var RaceTimeoutScale = 4.0
+
+// TimeoutOverrider can be implemented by containers passed to RunTests and
+// RunBenchmarks to override the timeout of individual methods. The returned
+// map is keyed by method name; overrides replace the duration passed to
+// WithTimeout and WithHardTimeout, and are scaled like any other timeout.
+type TimeoutOverrider interface {
+	Timeouts() map[string]time.Duration
+}
+
+var envTimeoutScale = sync.OnceValue(func() float64 {
+	scale, err := strconv.ParseFloat(os.Getenv(TimeoutScaleEnv), 64)
+	if err != nil || scale <= 0 {
+		return 1
+	}
+	return scale
+})
+
+// ScaleTimeout applies the timeout policy to d: it is multiplied by the
+// factor in TimeoutScaleEnv and, when the race detector is enabled, by
+// RaceTimeoutScale.
+func ScaleTimeout(d time.Duration) time.Duration {
+	scale := envTimeoutScale()
+	if raceEnabled {
+		scale *= RaceTimeoutScale
+	}
+	return time.Duration(float64(d) * scale)
+}
+
+// timeoutKey is the key used to store the effective timeout in the context
+type timeoutKey struct{}
+
+// TimeoutFromContext returns the effective timeout applied to the test by
+// WithTimeout or WithHardTimeout, after overrides and scaling
+func TimeoutFromContext(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(timeoutKey{}).(time.Duration)
+	return d, ok
+}
+
+// timeoutOverrideKey is the key used to pass a TimeoutOverrider's timeout
+// for a suite method to its timeout middleware
+type timeoutOverrideKey struct{}
+
+type timeoutOverride struct {
+	// parent is the state of the test running the suite
+	parent *testState
+	method string
+	d      time.Duration
+}
+
+// withTimeoutOverrides returns a wrapper to run the given suite method
+// with, carrying its timeout override if the container declares one
+func (w *W[T]) withTimeoutOverrides(container any, method string) *W[T] {
+	overrider, ok := container.(TimeoutOverrider)
+	if !ok {
+		return w
+	}
+	d, ok := overrider.Timeouts()[method]
+	if !ok {
+		return w
+	}
+	return w.WithContext(context.WithValue(w.ctx, timeoutOverrideKey{}, timeoutOverride{
+		parent: w.state,
+		method: method,
+		d:      d,
+	}))
+}
+
+// effectiveTimeout returns the timeout to apply to the test: its suite
+// method override if any, otherwise d, scaled by the timeout policy. The
+// result is recorded in the returned context.
+func effectiveTimeout[T Runner[T]](ctx context.Context, w *W[T], d time.Duration) (context.Context, time.Duration) {
+	if o, ok := ctx.Value(timeoutOverrideKey{}).(timeoutOverride); ok &&
+		o.parent == w.state.parent && o.method == w.BaseName() {
+		d = o.d
+	}
+	d = ScaleTimeout(d)
+	return context.WithValue(ctx, timeoutKey{}, d), d
+}
@@ -0,0 +1,67 @@
+package testctx_test
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+)
+
+type TimeoutSuite struct {
+	timeouts map[string]time.Duration
+}
+
+func (s TimeoutSuite) Timeouts() map[string]time.Duration {
+	return map[string]time.Duration{
+		"TestOverridden": time.Hour,
+	}
+}
+
+func (s TimeoutSuite) TestDefault(ctx context.Context, t *testctx.T) {
+	s.timeouts[t.BaseName()], _ = testctx.TimeoutFromContext(ctx)
+}
+
+func (s TimeoutSuite) TestOverridden(ctx context.Context, t *testctx.T) {
+	s.timeouts[t.BaseName()], _ = testctx.TimeoutFromContext(ctx)
+
+	t.Run("subtest", func(ctx context.Context, t *testctx.T) {
+		s.timeouts[t.BaseName()], _ = testctx.TimeoutFromContext(ctx)
+	})
+}
+
+func TestTimeoutOverrides(t *testing.T) {
+	suite := TimeoutSuite{timeouts: map[string]time.Duration{}}
+	testctx.New(t, testctx.WithTimeout[*testing.T](time.Minute)).RunTests(suite)
+
+	assert.Equal(t, map[string]time.Duration{
+		"TestDefault":    testctx.ScaleTimeout(time.Minute),
+		"TestOverridden": testctx.ScaleTimeout(time.Hour),
+		// Overrides only apply to the method itself, not its subtests
+		"subtest": testctx.ScaleTimeout(time.Minute),
+	}, suite.timeouts)
+}
+
+func TestTimeoutScaleEnv(t *testing.T) {
+	if inSubprocess() {
+		testctx.New(t, testctx.WithTimeout[*testing.T](time.Minute)).Run("scaled", func(ctx context.Context, t *testctx.T) {
+			timeout, _ := testctx.TimeoutFromContext(ctx)
+			t.Logf("timeout: %s", timeout)
+		})
+		return
+	}
+
+	out, passed := runSubprocess(t, "TestTimeoutScaleEnv", testctx.TimeoutScaleEnv+"=2.5")
+	assert.True(t, passed)
+	// The subprocess's scale replaces any we're running with, while the
+	// race detector scale still applies
+	ambient, err := strconv.ParseFloat(os.Getenv(testctx.TimeoutScaleEnv), 64)
+	if err != nil || ambient <= 0 {
+		ambient = 1
+	}
+	want := time.Duration(float64(testctx.ScaleTimeout(150*time.Second)) / ambient)
+	assert.Contains(t, out, "timeout: "+want.String())
+}
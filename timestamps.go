@@ -0,0 +1,59 @@
+package testctx
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TimestampConfig holds configuration for the WithTimestamps middleware
+type TimestampConfig struct {
+	// Format renders the prefix for a log message given the current time and
+	// the time elapsed since the start. Defaults to DefaultTimestampFormat.
+	Format func(now time.Time, elapsed time.Duration) string
+	// SinceRoot measures elapsed time from the start of the outermost test
+	// using this middleware, rather than from the start of the current test.
+	SinceRoot bool
+}
+
+// rootStartKey is the key used to store the root test start time in the context
+type rootStartKey struct{}
+
+// DefaultTimestampFormat renders prefixes like "[15:04:05.000 +1.5s] "
+func DefaultTimestampFormat(now time.Time, elapsed time.Duration) string {
+	return fmt.Sprintf("[%s +%s] ", now.Format("15:04:05.000"), elapsed.Round(time.Millisecond))
+}
+
+// WithTimestamps creates middleware that prefixes every message logged via
+// Log, Error, Fatal and Skip (and their formatted variants) with the
+// wall-clock time and the time elapsed since the test started. The prefix
+// appears both in the test output and in loggers added via WithLogger.
+func WithTimestamps[T Runner[T]](cfg ...TimestampConfig) Middleware[T] {
+	var c TimestampConfig
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	if c.Format == nil {
+		c.Format = DefaultTimestampFormat
+	}
+
+	return func(next RunFunc[T]) RunFunc[T] {
+		return func(ctx context.Context, w *W[T]) {
+			start := time.Now()
+			if root, ok := ctx.Value(rootStartKey{}).(time.Time); ok {
+				if c.SinceRoot {
+					start = root
+				}
+			} else {
+				ctx = context.WithValue(ctx, rootStartKey{}, start)
+			}
+
+			w = w.clone()
+			w.logPrefix = func() string {
+				now := time.Now()
+				return c.Format(now, now.Sub(start))
+			}
+			next(ctx, w)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package testctx_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dagger/testctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestamps(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+
+	tt := testctx.New(t, testctx.WithTimestamps[*testing.T]()).WithLogger(rec)
+	tt.Run("subtest", func(ctx context.Context, t *testctx.T) {
+		t.Log("hello")
+	})
+
+	msgs := rec.Messages()
+	assert.Len(t, msgs, 1)
+	assert.Regexp(t, regexp.MustCompile(`^\[\d\d:\d\d:\d\d\.\d{3} \+\d+(\.\d+)?m?s\] hello$`), msgs[0])
+}
+
+func TestTimestampsSinceRoot(t *testing.T) {
+	rec := testctx.NewLogRecorder()
+
+	var elapsed []time.Duration
+	tt := testctx.New(t, testctx.WithTimestamps[*testing.T](testctx.TimestampConfig{
+		SinceRoot: true,
+		Format: func(now time.Time, d time.Duration) string {
+			elapsed = append(elapsed, d)
+			return "[since root] "
+		},
+	})).WithLogger(rec)
+
+	tt.Run("parent", func(ctx context.Context, t *testctx.T) {
+		time.Sleep(100 * time.Millisecond)
+		t.Run("child", func(ctx context.Context, t *testctx.T) {
+			t.Log("hello")
+		})
+	})
+
+	assert.Equal(t, []string{"[since root] hello"}, rec.Messages())
+	// The child itself only just started, but the time is measured from
+	// the root test
+	require.Len(t, elapsed, 1)
+	assert.GreaterOrEqual(t, elapsed[0], 100*time.Millisecond)
+	assert.Less(t, elapsed[0], time.Minute)
+}